@@ -0,0 +1,327 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package envoy
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	envoy_config_core "github.com/cilium/proxy/go/envoy/config/core/v3"
+	envoy_service_discovery "github.com/cilium/proxy/go/envoy/service/discovery/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/cilium/cilium/pkg/backoff"
+	cilium_v2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
+	"github.com/cilium/cilium/pkg/time"
+)
+
+// defaultADSTypeURLs is requested when an ADSClientConfig leaves TypeURLs empty.
+var defaultADSTypeURLs = []string{
+	ListenerTypeURL,
+	RouteTypeURL,
+	ClusterTypeURL,
+	EndpointTypeURL,
+	SecretTypeURL,
+	ExtensionConfigTypeURL,
+}
+
+// resourceServer is the subset of xdsServer that ADSClient needs to feed ingested resources into the
+// same Upsert/Update path a local CEC CRD would go through.
+type resourceServer interface {
+	UpdateEnvoyResources(ctx context.Context, old, new Resources) error
+}
+
+// ADSClientConfig configures a single upstream xDS management server (e.g. Istio pilot) that an
+// ADSClient federates into Cilium's own Envoy resources, as if defined by a CiliumEnvoyConfig CRD.
+type ADSClientConfig struct {
+	// Address is the upstream xDS management server's gRPC target, e.g. "istiod.istio-system:15010".
+	Address string
+
+	// NodeID and NodeMetadata identify this Cilium instance to the upstream control plane.
+	NodeID       string
+	NodeMetadata *structpb.Struct
+
+	// CECNamespace and CECName are passed to ParseResources for every resource ingested from this
+	// source, so they are qualified the same way and cannot collide with resources from another source.
+	CECNamespace string
+	CECName      string
+
+	// PortAllocator allocates proxy ports for ingested Listeners that do not specify their own address.
+	PortAllocator PortAllocator
+
+	// TypeURLs is the allow-list of resource TypeURLs requested from this source; nil or empty
+	// requests every TypeURL ParseResources understands (see defaultADSTypeURLs).
+	TypeURLs []string
+
+	// Delta selects the Delta (incremental) xDS transport instead of State-of-the-World.
+	Delta bool
+}
+
+// ADSClient runs an ADS client against a single upstream xDS management server and injects what it
+// receives through ParseResources and xdsServer.UpdateEnvoyResources, the same pipeline a
+// CiliumEnvoyConfig CRD goes through; Cilium is the "Envoy" from the upstream server's point of view.
+type ADSClient struct {
+	cfg     ADSClientConfig
+	server  resourceServer
+	node    *envoy_config_core.Node
+	backoff backoff.Exponential
+
+	// current is the last snapshot successfully applied to 'server', the 'old' argument to the next
+	// UpdateEnvoyResources call.
+	current Resources
+
+	// versionInfo tracks the last accepted version_info per TypeURL on the SotW stream.
+	versionInfo map[string]string
+
+	// deltaState tracks the Delta stream's by-name view of every TypeURL's resources, folded forward
+	// on every response; it also seeds initial_resource_versions to resume after a reconnect.
+	deltaState map[string]map[string]deltaResource
+}
+
+// deltaResource is a single named resource as last seen on a Delta stream.
+type deltaResource struct {
+	version  string
+	resource *anypb.Any
+}
+
+// NewADSClient creates an ADSClient that will federate resources from cfg.Address into 'server'.
+// Call Run to start the connect/reconnect loop; it blocks until its context is canceled.
+func NewADSClient(cfg ADSClientConfig, server resourceServer) *ADSClient {
+	if len(cfg.TypeURLs) == 0 {
+		cfg.TypeURLs = defaultADSTypeURLs
+	}
+	return &ADSClient{
+		cfg:    cfg,
+		server: server,
+		node: &envoy_config_core.Node{
+			Id:       cfg.NodeID,
+			Metadata: cfg.NodeMetadata,
+		},
+		backoff: backoff.Exponential{
+			Min: time.Second,
+			Max: 30 * time.Second,
+		},
+		versionInfo: make(map[string]string, len(cfg.TypeURLs)),
+		deltaState:  make(map[string]map[string]deltaResource, len(cfg.TypeURLs)),
+	}
+}
+
+// Run connects to the upstream xDS server and ingests resources until ctx is canceled, reconnecting
+// with exponential backoff whenever the stream fails or the server closes it.
+func (a *ADSClient) Run(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.runOnce(ctx); err != nil {
+			log.WithError(err).WithField("address", a.cfg.Address).Warning("ADSClient: stream failed, reconnecting")
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(a.backoff.Duration(a.cfg.Address)):
+		}
+	}
+}
+
+// runOnce dials the upstream server and runs a single ADS stream to completion.
+func (a *ADSClient) runOnce(ctx context.Context) error {
+	conn, err := grpc.NewClient(a.cfg.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("dial %q: %w", a.cfg.Address, err)
+	}
+	defer conn.Close()
+
+	client := envoy_service_discovery.NewAggregatedDiscoveryServiceClient(conn)
+	if a.cfg.Delta {
+		return a.runDelta(ctx, client)
+	}
+	return a.runSotW(ctx, client)
+}
+
+// runSotW drives the State-of-the-World ADS stream: one initial DiscoveryRequest per allowed
+// TypeURL, then an ACK/NACK re-sent for every response carrying its version_info and response_nonce.
+func (a *ADSClient) runSotW(ctx context.Context, client envoy_service_discovery.AggregatedDiscoveryServiceClient) error {
+	stream, err := client.StreamAggregatedResources(ctx)
+	if err != nil {
+		return fmt.Errorf("open SotW stream: %w", err)
+	}
+
+	for _, typeURL := range a.cfg.TypeURLs {
+		if err := stream.Send(&envoy_service_discovery.DiscoveryRequest{
+			Node:    a.node,
+			TypeUrl: typeURL,
+		}); err != nil {
+			return fmt.Errorf("subscribe %s: %w", typeURL, err)
+		}
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("recv: %w", err)
+		}
+		typeURL := resp.GetTypeUrl()
+
+		req := &envoy_service_discovery.DiscoveryRequest{
+			Node:          a.node,
+			TypeUrl:       typeURL,
+			ResponseNonce: resp.GetNonce(),
+			// Ack the previously accepted version until this response is
+			// proven good below; a NACK must keep referring to it.
+			VersionInfo: a.versionInfo[typeURL],
+		}
+		if ackErr := a.applyResources(ctx, typeURL, resp.GetResources()); ackErr != nil {
+			log.WithError(ackErr).WithField("type-url", typeURL).Warning("ADSClient: rejecting resources, NACKing")
+			req.ErrorDetail = &status.Status{Code: int32(codes.InvalidArgument), Message: ackErr.Error()}
+		} else {
+			a.versionInfo[typeURL] = resp.GetVersionInfo()
+			req.VersionInfo = resp.GetVersionInfo()
+		}
+		if err := stream.Send(req); err != nil {
+			return fmt.Errorf("ack/nack %s: %w", typeURL, err)
+		}
+	}
+}
+
+// runDelta drives the Delta (incremental) ADS stream: one initial DeltaDiscoveryRequest per allowed
+// TypeURL seeded with initial_resource_versions, then folds every response into deltaState before ACKing/NACKing it.
+func (a *ADSClient) runDelta(ctx context.Context, client envoy_service_discovery.AggregatedDiscoveryServiceClient) error {
+	stream, err := client.DeltaAggregatedResources(ctx)
+	if err != nil {
+		return fmt.Errorf("open delta stream: %w", err)
+	}
+
+	for _, typeURL := range a.cfg.TypeURLs {
+		if err := stream.Send(&envoy_service_discovery.DeltaDiscoveryRequest{
+			Node:                    a.node,
+			TypeUrl:                 typeURL,
+			InitialResourceVersions: a.initialResourceVersions(typeURL),
+		}); err != nil {
+			return fmt.Errorf("subscribe %s: %w", typeURL, err)
+		}
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("recv: %w", err)
+		}
+		typeURL := resp.GetTypeUrl()
+
+		req := &envoy_service_discovery.DeltaDiscoveryRequest{
+			Node:          a.node,
+			TypeUrl:       typeURL,
+			ResponseNonce: resp.GetNonce(),
+		}
+		if ackErr := a.applyDelta(ctx, typeURL, resp.GetResources(), resp.GetRemovedResources()); ackErr != nil {
+			log.WithError(ackErr).WithField("type-url", typeURL).Warning("ADSClient: rejecting resources, NACKing")
+			req.ErrorDetail = &status.Status{Code: int32(codes.InvalidArgument), Message: ackErr.Error()}
+		}
+		if err := stream.Send(req); err != nil {
+			return fmt.Errorf("ack/nack %s: %w", typeURL, err)
+		}
+	}
+}
+
+// initialResourceVersions builds the initial_resource_versions map for 'typeURL' from deltaState, so
+// a (re)connecting stream resumes from what was already applied.
+func (a *ADSClient) initialResourceVersions(typeURL string) map[string]string {
+	names := a.deltaState[typeURL]
+	if len(names) == 0 {
+		return nil
+	}
+	versions := make(map[string]string, len(names))
+	for name, r := range names {
+		versions[name] = r.version
+	}
+	return versions
+}
+
+// applyResources re-parses the full SotW set of 'typeURL' resources and applies the result via ingest.
+func (a *ADSClient) applyResources(ctx context.Context, typeURL string, resources []*anypb.Any) error {
+	xdsResources := make([]cilium_v2.XDSResource, 0, len(resources))
+	for _, r := range resources {
+		xdsResources = append(xdsResources, cilium_v2.XDSResource{Any: r})
+	}
+	return a.ingest(ctx, typeURL, xdsResources)
+}
+
+// applyDelta folds an incremental Delta response into a copy of deltaState and re-parses the
+// resulting full set, only committing that copy back once ingest succeeds - so a NACKed or
+// unparseable version is never claimed by initialResourceVersions on a later reconnect.
+func (a *ADSClient) applyDelta(ctx context.Context, typeURL string, resources []*envoy_service_discovery.Resource, removed []string) error {
+	names := make(map[string]deltaResource, len(a.deltaState[typeURL]))
+	for name, r := range a.deltaState[typeURL] {
+		names[name] = r
+	}
+	for _, r := range resources {
+		names[r.GetName()] = deltaResource{version: r.GetVersion(), resource: r.GetResource()}
+	}
+	for _, name := range removed {
+		delete(names, name)
+	}
+
+	xdsResources := make([]cilium_v2.XDSResource, 0, len(names))
+	for _, r := range names {
+		xdsResources = append(xdsResources, cilium_v2.XDSResource{Any: r.resource})
+	}
+	if err := a.ingest(ctx, typeURL, xdsResources); err != nil {
+		return err
+	}
+	a.deltaState[typeURL] = names
+	return nil
+}
+
+// ingest parses 'xdsResources' (all of 'typeURL') and, on success, applies them to 'server' as an
+// update from the previously applied snapshot, replacing only that TypeURL's slice in Resources.
+func (a *ADSClient) ingest(ctx context.Context, typeURL string, xdsResources []cilium_v2.XDSResource) error {
+	parsed, err := ParseResources(a.cfg.CECNamespace, a.cfg.CECName, xdsResources, true, a.cfg.PortAllocator, false, false, true)
+	if err != nil {
+		return err
+	}
+	old := a.current
+	next := replaceResourcesOfType(old, typeURL, parsed)
+	if err := a.server.UpdateEnvoyResources(ctx, old, next); err != nil {
+		return err
+	}
+	a.current = next
+	return nil
+}
+
+// replaceResourcesOfType returns a copy of 'base' with only the slice for 'typeURL' replaced by the
+// matching slice from 'parsed', so ADSClient can apply one TypeURL at a time without disturbing the rest.
+func replaceResourcesOfType(base Resources, typeURL string, parsed Resources) Resources {
+	next := base
+	switch typeURL {
+	case ListenerTypeURL:
+		next.Listeners = parsed.Listeners
+		// Carry over portAllocationCallbacks too, or ADS-ingested listeners never get their
+		// AckProxyPort/ReleaseProxyPort callback invoked.
+		next.portAllocationCallbacks = parsed.portAllocationCallbacks
+	case RouteTypeURL:
+		next.Routes = parsed.Routes
+	case ClusterTypeURL:
+		next.Clusters = parsed.Clusters
+	case EndpointTypeURL:
+		next.Endpoints = parsed.Endpoints
+	case SecretTypeURL:
+		next.Secrets = parsed.Secrets
+	case ExtensionConfigTypeURL:
+		next.ExtensionConfigs = parsed.ExtensionConfigs
+	}
+	return next
+}
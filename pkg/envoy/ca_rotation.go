@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package envoy
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	envoy_config_core "github.com/cilium/proxy/go/envoy/config/core/v3"
+	envoy_config_tls "github.com/cilium/proxy/go/envoy/extensions/transport_sockets/tls/v3"
+
+	"github.com/cilium/cilium/pkg/completion"
+	"github.com/cilium/cilium/pkg/envoy/xds"
+	"github.com/cilium/cilium/pkg/lock"
+	"github.com/cilium/cilium/pkg/option"
+	"github.com/cilium/cilium/pkg/time"
+)
+
+// CARotation describes an in-progress CA rollout for one SDS validation secret: rather than a hard
+// cutover that would reset sessions the instant either side stops trusting the other's root, both
+// roots plus a cross-signing intermediate are served together until every Envoy has acked the new one.
+type CARotation struct {
+	// Name is the SDS secret name (already CEC-namespace-qualified) this rotation applies to.
+	Name string
+	// OldCAPEM and NewCAPEM are the outgoing and incoming trust roots.
+	OldCAPEM, NewCAPEM []byte
+	// CrossSignedIntermediatePEM certifies NewCAPEM's key using OldCAPEM so a chain ending in it
+	// validates against OldCAPEM even though the leaf was issued by NewCAPEM.
+	CrossSignedIntermediatePEM []byte
+	// OldLeaf and NewLeaf are the leaf certificate/key pairs signed by OldCAPEM and NewCAPEM
+	// respectively, presented together so a peer on either root can complete the handshake.
+	OldLeaf, NewLeaf *envoy_config_tls.TlsCertificate
+}
+
+// validateCrossSignedIntermediate checks CrossSignedIntermediatePEM carries a CA Basic Constraints
+// extension (OID 2.5.29.19), which Envoy requires of every intermediate in a chain.
+func (r *CARotation) validateCrossSignedIntermediate() error {
+	block, _ := pem.Decode(r.CrossSignedIntermediatePEM)
+	if block == nil {
+		return fmt.Errorf("CA rotation %q: cross-signed intermediate is not valid PEM", r.Name)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("CA rotation %q: cross-signed intermediate: %w", r.Name, err)
+	}
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return fmt.Errorf("CA rotation %q: cross-signed intermediate lacks a valid CA Basic Constraints (OID 2.5.29.19)", r.Name)
+	}
+	return nil
+}
+
+// trustBundle is the TrustedCa inline bytes for the rotation's duration: new root, cross-signing
+// intermediate, then old root, so a peer trusting either root validates the chain.
+func (r *CARotation) trustBundle() []byte {
+	bundle := make([]byte, 0, len(r.NewCAPEM)+len(r.CrossSignedIntermediatePEM)+len(r.OldCAPEM))
+	bundle = append(bundle, r.NewCAPEM...)
+	bundle = append(bundle, r.CrossSignedIntermediatePEM...)
+	bundle = append(bundle, r.OldCAPEM...)
+	return bundle
+}
+
+// validationSecret builds the Secret resource to upsert for the duration of
+// the rotation.
+func (r *CARotation) validationSecret() *envoy_config_tls.Secret {
+	return &envoy_config_tls.Secret{
+		Name: r.Name,
+		Type: &envoy_config_tls.Secret_ValidationContext{
+			ValidationContext: &envoy_config_tls.CertificateValidationContext{
+				TrustedCa: &envoy_config_core.DataSource{
+					Specifier: &envoy_config_core.DataSource_InlineBytes{InlineBytes: r.trustBundle()},
+				},
+			},
+		},
+	}
+}
+
+// settledSecret builds the Secret to upsert once the rotation completes: only the new root remains.
+func (r *CARotation) settledSecret() *envoy_config_tls.Secret {
+	return &envoy_config_tls.Secret{
+		Name: r.Name,
+		Type: &envoy_config_tls.Secret_ValidationContext{
+			ValidationContext: &envoy_config_tls.CertificateValidationContext{
+				TrustedCa: &envoy_config_core.DataSource{
+					Specifier: &envoy_config_core.DataSource_InlineBytes{InlineBytes: append([]byte{}, r.NewCAPEM...)},
+				},
+			},
+		},
+	}
+}
+
+// LeafCertificates returns the old- and new-signed leaf pairs, in offer order, for a CommonTlsContext's
+// TlsCertificates so a peer validating against either root can complete the handshake.
+func (r *CARotation) LeafCertificates() []*envoy_config_tls.TlsCertificate {
+	var certs []*envoy_config_tls.TlsCertificate
+	if r.OldLeaf != nil {
+		certs = append(certs, r.OldLeaf)
+	}
+	if r.NewLeaf != nil {
+		certs = append(certs, r.NewLeaf)
+	}
+	return certs
+}
+
+// RotateCA begins (or re-pushes) a CA rotation: it validates the cross-signed intermediate and
+// upserts the combined trust bundle. Call PruneCARotations later to retire the old root once safe.
+func (s *xdsServer) RotateCA(ctx context.Context, rotation *CARotation) error {
+	if err := rotation.validateCrossSignedIntermediate(); err != nil {
+		return err
+	}
+	secret := rotation.validationSecret()
+	revertDelta := deltaCacheRevertFunc(SecretTypeURL, secret.Name)
+	updateDeltaCache(SecretTypeURL, secret.Name, secret)
+	wg := completion.NewWaitGroup(ctx)
+	revert := s.upsertSecret(secret.Name, secret, wg, nil)
+	if err := wg.Wait(); err != nil {
+		revert(nil)
+		revertDelta()
+		return fmt.Errorf("RotateCA: %s: %w", rotation.Name, err)
+	}
+	registerCARotation(rotation)
+	return nil
+}
+
+// caRotations holds every CA rotation RotateCA has started that PruneCARotations has not yet completed.
+var (
+	caRotationsMutex lock.Mutex
+	caRotations      = map[string]*CARotation{}
+)
+
+func registerCARotation(rotation *CARotation) {
+	caRotationsMutex.Lock()
+	defer caRotationsMutex.Unlock()
+	caRotations[rotation.Name] = rotation
+}
+
+// secretSubscriptions tracks every live Delta stream's SecretTypeURL Subscription, so
+// PruneCARotations can tell once every connected Envoy has acked a rotation's new version.
+var (
+	secretSubscriptionsMutex lock.Mutex
+	secretSubscriptions      = map[*xds.Subscription]struct{}{}
+)
+
+func registerSecretSubscription(sub *xds.Subscription) {
+	secretSubscriptionsMutex.Lock()
+	defer secretSubscriptionsMutex.Unlock()
+	secretSubscriptions[sub] = struct{}{}
+}
+
+func unregisterSecretSubscription(sub *xds.Subscription) {
+	secretSubscriptionsMutex.Lock()
+	defer secretSubscriptionsMutex.Unlock()
+	delete(secretSubscriptions, sub)
+}
+
+// allSubscriptionsAcked reports whether every connected Delta stream's SecretTypeURL Subscription has
+// acked 'name' at 'version'; an empty secretSubscriptions counts as not yet acked, not vacuously true.
+func allSubscriptionsAcked(name string, version xds.ResourceVersion) bool {
+	secretSubscriptionsMutex.Lock()
+	defer secretSubscriptionsMutex.Unlock()
+	if len(secretSubscriptions) == 0 {
+		return false
+	}
+	for sub := range secretSubscriptions {
+		acked, ok := sub.Acked(name)
+		if !ok || acked != version {
+			return false
+		}
+	}
+	return true
+}
+
+// sotwAckTimeout bounds sotwAcked's wait so a stuck SotW Envoy only delays its own rotation instead
+// of wedging the shared pruner goroutine for every other one.
+const sotwAckTimeout = 5 * time.Second
+
+// sotwAcked re-upserts rotation's current validation secret over SotW and waits (bounded by
+// sotwAckTimeout) for every connected SotW Envoy to ack it, reverting and returning false on failure.
+func (s *xdsServer) sotwAcked(ctx context.Context, rotation *CARotation) bool {
+	ctx, cancel := context.WithTimeout(ctx, sotwAckTimeout)
+	defer cancel()
+	wg := completion.NewWaitGroup(ctx)
+	revert := s.upsertSecret(rotation.Name, rotation.validationSecret(), wg, nil)
+	if err := wg.Wait(); err != nil {
+		revert(nil)
+		return false
+	}
+	return true
+}
+
+// PruneCARotations completes every registered rotation whose new secret has been confirmed acked by
+// every connected Envoy (Delta and SotW), dropping the old root; an unconfirmed one is left for next time.
+func (s *xdsServer) PruneCARotations(ctx context.Context) {
+	caRotationsMutex.Lock()
+	rotations := make([]*CARotation, 0, len(caRotations))
+	for _, rotation := range caRotations {
+		rotations = append(rotations, rotation)
+	}
+	caRotationsMutex.Unlock()
+
+	for _, rotation := range rotations {
+		if !s.sotwAcked(ctx, rotation) {
+			continue
+		}
+		if option.Config.EnableEnvoyDeltaXDS {
+			_, version, ok := deltaCaches[SecretTypeURL].Get(rotation.Name)
+			if !ok || !allSubscriptionsAcked(rotation.Name, version) {
+				continue
+			}
+		}
+		secret := rotation.settledSecret()
+		updateDeltaCache(SecretTypeURL, secret.Name, secret)
+		s.upsertSecret(secret.Name, secret, nil, nil)
+		caRotationsMutex.Lock()
+		delete(caRotations, rotation.Name)
+		caRotationsMutex.Unlock()
+		log.Infof("PruneCARotations: completed CA rotation for secret %q", rotation.Name)
+	}
+}
+
+// StartCARotationPruner runs PruneCARotations on 'interval' until ctx is canceled.
+func StartCARotationPruner(ctx context.Context, s *xdsServer, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.PruneCARotations(ctx)
+			}
+		}
+	}()
+}
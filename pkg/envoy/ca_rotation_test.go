@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package envoy
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+
+	"github.com/cilium/cilium/pkg/envoy/xds"
+)
+
+func generateTestCert(t *testing.T, isCA bool) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestValidateCrossSignedIntermediate(t *testing.T) {
+	rotation := &CARotation{Name: "test-namespace/test-cec/secret", CrossSignedIntermediatePEM: generateTestCert(t, true)}
+	if err := rotation.validateCrossSignedIntermediate(); err != nil {
+		t.Fatalf("expected a CA certificate to validate, got %v", err)
+	}
+}
+
+func TestValidateCrossSignedIntermediateRejectsNonCA(t *testing.T) {
+	rotation := &CARotation{Name: "test-namespace/test-cec/secret", CrossSignedIntermediatePEM: generateTestCert(t, false)}
+	if err := rotation.validateCrossSignedIntermediate(); err == nil {
+		t.Fatal("expected a non-CA certificate to be rejected")
+	}
+}
+
+func TestValidateCrossSignedIntermediateRejectsGarbage(t *testing.T) {
+	rotation := &CARotation{Name: "test-namespace/test-cec/secret", CrossSignedIntermediatePEM: []byte("not a pem")}
+	if err := rotation.validateCrossSignedIntermediate(); err == nil {
+		t.Fatal("expected non-PEM input to be rejected")
+	}
+}
+
+func TestCARotationTrustBundleOrder(t *testing.T) {
+	rotation := &CARotation{
+		OldCAPEM:                   []byte("old"),
+		NewCAPEM:                   []byte("new"),
+		CrossSignedIntermediatePEM: []byte("cross"),
+	}
+	got := rotation.trustBundle()
+	want := []byte("newcrossold")
+	if !bytes.Equal(got, want) {
+		t.Fatalf("trustBundle order: got %q, want %q", got, want)
+	}
+}
+
+func TestCARotationValidationSecretServesBothRoots(t *testing.T) {
+	rotation := &CARotation{
+		Name:                       "test-namespace/test-cec/secret",
+		OldCAPEM:                   []byte("old"),
+		NewCAPEM:                   []byte("new"),
+		CrossSignedIntermediatePEM: []byte("cross"),
+	}
+	secret := rotation.validationSecret()
+	inline := secret.GetValidationContext().GetTrustedCa().GetInlineBytes()
+	if !bytes.Contains(inline, []byte("old")) || !bytes.Contains(inline, []byte("new")) {
+		t.Fatalf("expected the in-progress validation secret to trust both roots, got %q", inline)
+	}
+}
+
+func TestCARotationSettledSecretDropsOldRoot(t *testing.T) {
+	rotation := &CARotation{
+		Name:                       "test-namespace/test-cec/secret",
+		OldCAPEM:                   []byte("old"),
+		NewCAPEM:                   []byte("new"),
+		CrossSignedIntermediatePEM: []byte("cross"),
+	}
+	secret := rotation.settledSecret()
+	inline := secret.GetValidationContext().GetTrustedCa().GetInlineBytes()
+	if !bytes.Equal(inline, []byte("new")) {
+		t.Fatalf("expected the settled secret to trust only the new root, got %q", inline)
+	}
+}
+
+func TestAllSubscriptionsAckedNoSubscriptionsIsNotAcked(t *testing.T) {
+	if allSubscriptionsAcked("test-namespace/test-cec/secret", "v1") {
+		t.Fatal("an empty set of subscriptions should never be treated as confirmed acked")
+	}
+}
+
+func TestAllSubscriptionsAckedRequiresEverySubscription(t *testing.T) {
+	acked := xds.NewSubscription(nil, nil)
+	acked.Ack("test-namespace/test-cec/secret", "v1")
+	unacked := xds.NewSubscription(nil, nil)
+
+	registerSecretSubscription(acked)
+	registerSecretSubscription(unacked)
+	defer unregisterSecretSubscription(acked)
+	defer unregisterSecretSubscription(unacked)
+
+	if allSubscriptionsAcked("test-namespace/test-cec/secret", "v1") {
+		t.Fatal("expected a still-unacked subscription to block confirmation")
+	}
+
+	unacked.Ack("test-namespace/test-cec/secret", "v1")
+	if !allSubscriptionsAcked("test-namespace/test-cec/secret", "v1") {
+		t.Fatal("expected confirmation once every subscription has acked")
+	}
+}
@@ -4,10 +4,10 @@
 package envoy
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 
-	cilium "github.com/cilium/proxy/go/cilium/api"
 	envoy_config_cluster "github.com/cilium/proxy/go/envoy/config/cluster/v3"
 	envoy_config_core "github.com/cilium/proxy/go/envoy/config/core/v3"
 	envoy_config_endpoint "github.com/cilium/proxy/go/envoy/config/endpoint/v3"
@@ -16,25 +16,124 @@ import (
 	envoy_config_http "github.com/cilium/proxy/go/envoy/extensions/filters/network/http_connection_manager/v3"
 	envoy_config_tcp "github.com/cilium/proxy/go/envoy/extensions/filters/network/tcp_proxy/v3"
 	envoy_config_tls "github.com/cilium/proxy/go/envoy/extensions/transport_sockets/tls/v3"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/anypb"
-	"google.golang.org/protobuf/types/known/wrapperspb"
 
 	"github.com/cilium/cilium/pkg/completion"
 	_ "github.com/cilium/cilium/pkg/envoy/resource"
 	"github.com/cilium/cilium/pkg/envoy/xds"
 	cilium_v2 "github.com/cilium/cilium/pkg/k8s/apis/cilium.io/v2"
+	"github.com/cilium/cilium/pkg/lock"
 	"github.com/cilium/cilium/pkg/option"
 	"github.com/cilium/cilium/pkg/policy/api"
 	"github.com/cilium/cilium/pkg/time"
 )
 
+// deltaCaches holds the current version of every resource pushed to Envoy over Delta xDS, keyed by TypeURL.
+var deltaCaches = map[string]*xds.DeltaCache{
+	ListenerTypeURL: xds.NewDeltaCache(),
+	RouteTypeURL:    xds.NewDeltaCache(),
+	ClusterTypeURL:  xds.NewDeltaCache(),
+	EndpointTypeURL: xds.NewDeltaCache(),
+	SecretTypeURL:   xds.NewDeltaCache(),
+}
+
+// deltaNotifiers wakes up live Delta streams subscribed to a TypeURL when its DeltaCache changes.
+var deltaNotifiers = map[string]*xds.Broadcaster{
+	ListenerTypeURL: xds.NewBroadcaster(),
+	RouteTypeURL:    xds.NewBroadcaster(),
+	ClusterTypeURL:  xds.NewBroadcaster(),
+	EndpointTypeURL: xds.NewBroadcaster(),
+	SecretTypeURL:   xds.NewBroadcaster(),
+}
+
+// updateDeltaCache records 'resource' as the current version of 'name' for 'typeURL' and notifies subscribers; a no-op when delta xDS is disabled.
+func updateDeltaCache(typeURL, name string, resource proto.Message) {
+	if !option.Config.EnableEnvoyDeltaXDS {
+		return
+	}
+	cache := deltaCaches[typeURL]
+	if cache == nil {
+		return
+	}
+	_, changed, err := cache.Update(name, resource)
+	if err != nil {
+		log.WithError(err).Warningf("updateDeltaCache: failed to hash %s %q", typeURL, name)
+		return
+	}
+	if changed {
+		if notifier := deltaNotifiers[typeURL]; notifier != nil {
+			notifier.Publish()
+		}
+		cascadeChildRepush(typeURL, resource)
+	}
+}
+
+// deltaCacheRevertFunc snapshots 'name's current DeltaCache entry under 'typeURL' and returns a func restoring it; a no-op when delta xDS is disabled.
+func deltaCacheRevertFunc(typeURL, name string) func() {
+	if !option.Config.EnableEnvoyDeltaXDS {
+		return func() {}
+	}
+	cache := deltaCaches[typeURL]
+	if cache == nil {
+		return func() {}
+	}
+	prev, _, hadPrev := cache.Get(name)
+	return func() {
+		if hadPrev {
+			cache.Update(name, prev)
+		} else {
+			cache.Delete(name)
+		}
+		if notifier := deltaNotifiers[typeURL]; notifier != nil {
+			notifier.Publish()
+		}
+	}
+}
+
+// cascadeChildRepush re-pushes 'resource's children (per ChildResourceLister) even if unchanged, since
+// Envoy drops a Cluster's ClusterLoadAssignment and a Listener's RDS routes whenever the parent updates (envoyproxy/envoy#13009).
+func cascadeChildRepush(typeURL string, resource proto.Message) {
+	lister, ok := resourceParsers[typeURL].(ChildResourceLister)
+	if !ok {
+		return
+	}
+	for childTypeURL, names := range lister.ChildResources(resource) {
+		cache := deltaCaches[childTypeURL]
+		if cache == nil {
+			continue
+		}
+		for _, name := range names {
+			if cache.Touch(name) {
+				if notifier := deltaNotifiers[childTypeURL]; notifier != nil {
+					notifier.Publish()
+				}
+			}
+		}
+	}
+}
+
+// deleteDeltaCache removes 'name' from the delta cache for 'typeURL' and notifies subscribers.
+func deleteDeltaCache(typeURL, name string) {
+	if !option.Config.EnableEnvoyDeltaXDS {
+		return
+	}
+	if cache := deltaCaches[typeURL]; cache != nil {
+		cache.Delete(name)
+		if notifier := deltaNotifiers[typeURL]; notifier != nil {
+			notifier.Publish()
+		}
+	}
+}
+
 // Resources contains all Envoy resources parsed from a CiliumEnvoyConfig CRD
 type Resources struct {
-	Listeners []*envoy_config_listener.Listener
-	Secrets   []*envoy_config_tls.Secret
-	Routes    []*envoy_config_route.RouteConfiguration
-	Clusters  []*envoy_config_cluster.Cluster
-	Endpoints []*envoy_config_endpoint.ClusterLoadAssignment
+	Listeners        []*envoy_config_listener.Listener
+	Secrets          []*envoy_config_tls.Secret
+	Routes           []*envoy_config_route.RouteConfiguration
+	Clusters         []*envoy_config_cluster.Cluster
+	Endpoints        []*envoy_config_endpoint.ClusterLoadAssignment
+	ExtensionConfigs []*envoy_config_core.TypedExtensionConfig
 
 	// Callback functions that are called if the corresponding Listener change was successfully acked by Envoy
 	portAllocationCallbacks map[string]func(context.Context) error
@@ -143,8 +242,18 @@ func qualifyRouteConfigurationResourceNames(namespace, name string, routeConfig
 // names.
 // Parameter `newResources` is passed as `true` when parsing resources that are being added or are the new version of the resources being updated,
 // and as `false` if the resources are being removed or are the old version of the resources being updated.
+// Per-TypeURL handling (type-asserting the message, qualifying names, applying Cilium-specific
+// mutations, and validating) is delegated to the ResourceParser registered for that TypeURL in
+// resourceParsers; see RegisterResourceParser to add support for additional resource types.
 func ParseResources(cecNamespace string, cecName string, anySlice []cilium_v2.XDSResource, validate bool, portAllocator PortAllocator, isL7LB bool, useOriginalSourceAddr bool, newResources bool) (Resources, error) {
 	resources := Resources{}
+	opts := ParseOptions{
+		CECNamespace:          cecNamespace,
+		CECName:               cecName,
+		PortAllocator:         portAllocator,
+		IsL7LB:                isL7LB,
+		UseOriginalSourceAddr: useOriginalSourceAddr,
+	}
 	for _, r := range anySlice {
 		// Skip empty TypeURLs, which are left behind when Unmarshaling resource JSON fails
 		if r.TypeUrl == "" {
@@ -155,271 +264,85 @@ func ParseResources(cecNamespace string, cecName string, anySlice []cilium_v2.XD
 			return Resources{}, err
 		}
 		typeURL := r.GetTypeUrl()
-		switch typeURL {
-		case ListenerTypeURL:
-			listener, ok := message.(*envoy_config_listener.Listener)
-			if !ok {
-				return Resources{}, fmt.Errorf("Invalid type for Listener: %T", message)
-			}
-			// Check that a listener name is provided and that it is unique within this CEC
-			if listener.Name == "" {
-				return Resources{}, fmt.Errorf("'Listener name not provided")
-			}
-			for i := range resources.Listeners {
-				if listener.Name == resources.Listeners[i].Name {
-					return Resources{}, fmt.Errorf("Duplicate Listener name %q", listener.Name)
-				}
-			}
-
-			if option.Config.EnableBPFTProxy {
-				// Envoy since 1.20.0 uses SO_REUSEPORT on listeners by default.
-				// BPF TPROXY is currently not compatible with SO_REUSEPORT, so
-				// disable it.  Note that this may degrade Envoy performance.
-				listener.EnableReusePort = &wrapperspb.BoolValue{Value: false}
-			}
-
-			// Figure out if this is an internal listener
-			isInternalListener := listener.GetInternalListener() != nil
-
-			// Only inject Cilium filters if Cilium allocates listener address
-			injectCiliumFilters := listener.GetAddress() == nil && !isInternalListener
-
-			// Inject Cilium bpf metadata listener filter, if not already present.
-			if !isInternalListener {
-				found := false
-				for _, lf := range listener.ListenerFilters {
-					if lf.Name == "cilium.bpf_metadata" {
-						found = true
-						break
-					}
-				}
-				if !found {
-					listener.ListenerFilters = append(listener.ListenerFilters, getListenerFilter(false /* egress */, useOriginalSourceAddr, isL7LB))
-				}
-			}
-
-			// Fill in SDS & RDS config source if unset
-			for _, fc := range listener.FilterChains {
-				fillInTransportSocketXDS(cecNamespace, cecName, fc.TransportSocket)
-				foundCiliumNetworkFilter := false
-				for i, filter := range fc.Filters {
-					if filter.Name == "cilium.network" {
-						foundCiliumNetworkFilter = true
-					}
-					tc := filter.GetTypedConfig()
-					if tc == nil {
-						continue
-					}
-					switch tc.GetTypeUrl() {
-					case HttpConnectionManagerTypeURL:
-						any, err := tc.UnmarshalNew()
-						if err != nil {
-							continue
-						}
-						hcmConfig, ok := any.(*envoy_config_http.HttpConnectionManager)
-						if !ok {
-							continue
-						}
-						updated := false
-						if rds := hcmConfig.GetRds(); rds != nil {
-							// Since we are prepending CEC namespace and name to Routes name,
-							// we must do the same here to point to the correct Route resource.
-							if rds.RouteConfigName != "" {
-								rds.RouteConfigName, updated = api.ResourceQualifiedName(cecNamespace, cecName, rds.RouteConfigName, api.ForceNamespace)
-							}
-							if rds.ConfigSource == nil {
-								rds.ConfigSource = ciliumXDS
-								updated = true
-							}
-						}
-						if routeConfig := hcmConfig.GetRouteConfig(); routeConfig != nil {
-							if qualifyRouteConfigurationResourceNames(cecNamespace, cecName, routeConfig) {
-								updated = true
-							}
-						}
-						if injectCiliumFilters {
-							l7FilterUpdated := injectCiliumL7Filter(hcmConfig)
-							updated = updated || l7FilterUpdated
-						}
-						if updated {
-							filter.ConfigType = &envoy_config_listener.Filter_TypedConfig{
-								TypedConfig: toAny(hcmConfig),
-							}
-						}
-					case TCPProxyTypeURL:
-						any, err := tc.UnmarshalNew()
-						if err != nil {
-							continue
-						}
-						tcpProxy, ok := any.(*envoy_config_tcp.TcpProxy)
-						if !ok {
-							continue
-						}
-
-						if qualifyTcpProxyResourceNames(cecNamespace, cecName, tcpProxy) {
-							filter.ConfigType = &envoy_config_listener.Filter_TypedConfig{
-								TypedConfig: toAny(tcpProxy),
-							}
-						}
-					default:
-						continue
-					}
-					if injectCiliumFilters {
-						if !foundCiliumNetworkFilter {
-							// Inject Cilium network filter just before the HTTP Connection Manager or TCPProxy filter
-							fc.Filters = append(fc.Filters[:i+1], fc.Filters[i:]...)
-							fc.Filters[i] = &envoy_config_listener.Filter{
-								Name: "cilium.network",
-								ConfigType: &envoy_config_listener.Filter_TypedConfig{
-									TypedConfig: toAny(&cilium.NetworkFilter{}),
-								},
-							}
-						}
-					}
-					break // Done with this filter chain
-				}
-			}
-
-			name := listener.Name
-			listener.Name, _ = api.ResourceQualifiedName(cecNamespace, cecName, listener.Name, api.ForceNamespace)
+		parser, ok := resourceParsers[typeURL]
+		if !ok {
+			return Resources{}, fmt.Errorf("Unsupported type: %s", typeURL)
+		}
+		parsed, err := parser.Parse(message)
+		if err != nil {
+			return Resources{}, err
+		}
 
-			if validate {
-				if err := listener.Validate(); err != nil {
-					return Resources{}, fmt.Errorf("ParseResources: Could not validate Listener (%s): %s", err, listener.String())
+		// Check uniqueness within this CEC before any of the resource's
+		// own name qualification runs below.
+		switch typed := parsed.(type) {
+		case *envoy_config_listener.Listener:
+			for i := range resources.Listeners {
+				if typed.Name == resources.Listeners[i].Name {
+					return Resources{}, fmt.Errorf("Duplicate Listener name %q", typed.Name)
 				}
 			}
-			resources.Listeners = append(resources.Listeners, listener)
-
-			log.Debugf("ParseResources: Parsed listener %q: %v", name, listener)
-
-		case RouteTypeURL:
-			route, ok := message.(*envoy_config_route.RouteConfiguration)
-			if !ok {
-				return Resources{}, fmt.Errorf("Invalid type for Route: %T", message)
-			}
-			// Check that a Route name is provided and that it is unique within this CEC
-			if route.Name == "" {
-				return Resources{}, fmt.Errorf("RouteConfiguration name not provided")
-			}
+		case *envoy_config_route.RouteConfiguration:
 			for i := range resources.Routes {
-				if route.Name == resources.Routes[i].Name {
-					return Resources{}, fmt.Errorf("Duplicate Route name %q", route.Name)
+				if typed.Name == resources.Routes[i].Name {
+					return Resources{}, fmt.Errorf("Duplicate Route name %q", typed.Name)
 				}
 			}
-
-			qualifyRouteConfigurationResourceNames(cecNamespace, cecName, route)
-
-			name := route.Name
-			route.Name, _ = api.ResourceQualifiedName(cecNamespace, cecName, name, api.ForceNamespace)
-
-			if validate {
-				if err := route.Validate(); err != nil {
-					return Resources{}, fmt.Errorf("ParseResources: Could not validate RouteConfiguration (%s): %s", err, route.String())
-				}
-			}
-			resources.Routes = append(resources.Routes, route)
-
-			log.Debugf("ParseResources: Parsed route %q: %v", name, route)
-
-		case ClusterTypeURL:
-			cluster, ok := message.(*envoy_config_cluster.Cluster)
-			if !ok {
-				return Resources{}, fmt.Errorf("Invalid type for Route: %T", message)
-			}
-			// Check that a Cluster name is provided and that it is unique within this CEC
-			if cluster.Name == "" {
-				return Resources{}, fmt.Errorf("Cluster name not provided")
-			}
+		case *envoy_config_cluster.Cluster:
 			for i := range resources.Clusters {
-				if cluster.Name == resources.Clusters[i].Name {
-					return Resources{}, fmt.Errorf("Duplicate Cluster name %q", cluster.Name)
-				}
-			}
-
-			fillInTransportSocketXDS(cecNamespace, cecName, cluster.TransportSocket)
-
-			// Fill in EDS config source if unset
-			if enum := cluster.GetType(); enum == envoy_config_cluster.Cluster_EDS {
-				if cluster.EdsClusterConfig == nil {
-					cluster.EdsClusterConfig = &envoy_config_cluster.Cluster_EdsClusterConfig{}
-				}
-				if cluster.EdsClusterConfig.EdsConfig == nil {
-					cluster.EdsClusterConfig.EdsConfig = ciliumXDS
-				}
-			}
-
-			if cluster.LoadAssignment != nil {
-				cluster.LoadAssignment.ClusterName, _ = api.ResourceQualifiedName(cecNamespace, cecName, cluster.LoadAssignment.ClusterName)
-			}
-
-			name := cluster.Name
-			cluster.Name, _ = api.ResourceQualifiedName(cecNamespace, cecName, name)
-
-			if validate {
-				if err := cluster.Validate(); err != nil {
-					return Resources{}, fmt.Errorf("ParseResources: Could not validate Cluster %q (%s): %s", cluster.Name, err, cluster.String())
+				if typed.Name == resources.Clusters[i].Name {
+					return Resources{}, fmt.Errorf("Duplicate Cluster name %q", typed.Name)
 				}
 			}
-			resources.Clusters = append(resources.Clusters, cluster)
-
-			log.Debugf("ParseResources: Parsed cluster %q: %v", name, cluster)
-
-		case EndpointTypeURL:
-			endpoints, ok := message.(*envoy_config_endpoint.ClusterLoadAssignment)
-			if !ok {
-				return Resources{}, fmt.Errorf("Invalid type for Route: %T", message)
-			}
-			// Check that a Cluster name is provided and that it is unique within this CEC
-			if endpoints.ClusterName == "" {
-				return Resources{}, fmt.Errorf("ClusterLoadAssignment cluster_name not provided")
-			}
+		case *envoy_config_endpoint.ClusterLoadAssignment:
 			for i := range resources.Endpoints {
-				if endpoints.ClusterName == resources.Endpoints[i].ClusterName {
-					return Resources{}, fmt.Errorf("Duplicate cluster_name %q", endpoints.ClusterName)
-				}
-			}
-
-			name := endpoints.ClusterName
-			endpoints.ClusterName, _ = api.ResourceQualifiedName(cecNamespace, cecName, name)
-
-			if validate {
-				if err := endpoints.Validate(); err != nil {
-					return Resources{}, fmt.Errorf("ParseResources: Could not validate ClusterLoadAssignment for cluster %q (%s): %s", endpoints.ClusterName, err, endpoints.String())
+				if typed.ClusterName == resources.Endpoints[i].ClusterName {
+					return Resources{}, fmt.Errorf("Duplicate cluster_name %q", typed.ClusterName)
 				}
 			}
-			resources.Endpoints = append(resources.Endpoints, endpoints)
-
-			log.Debugf("ParseResources: Parsed endpoints for cluster %q: %v", name, endpoints)
-
-		case SecretTypeURL:
-			secret, ok := message.(*envoy_config_tls.Secret)
-			if !ok {
-				return Resources{}, fmt.Errorf("Invalid type for Secret: %T", message)
-			}
-			// Check that a Secret name is provided and that it is unique within this CEC
-			if secret.Name == "" {
-				return Resources{}, fmt.Errorf("Secret name not provided")
-			}
+		case *envoy_config_tls.Secret:
 			for i := range resources.Secrets {
-				if secret.Name == resources.Secrets[i].Name {
-					return Resources{}, fmt.Errorf("Duplicate Secret name %q", secret.Name)
+				if typed.Name == resources.Secrets[i].Name {
+					return Resources{}, fmt.Errorf("Duplicate Secret name %q", typed.Name)
 				}
 			}
-
-			name := secret.Name
-			secret.Name, _ = api.ResourceQualifiedName(cecNamespace, cecName, name)
-
-			if validate {
-				if err := secret.Validate(); err != nil {
-					return Resources{}, fmt.Errorf("ParseResources: Could not validate Secret for cluster %q (%s)", secret.Name, err)
+		case *envoy_config_core.TypedExtensionConfig:
+			for i := range resources.ExtensionConfigs {
+				if typed.Name == resources.ExtensionConfigs[i].Name {
+					return Resources{}, fmt.Errorf("Duplicate TypedExtensionConfig name %q", typed.Name)
 				}
 			}
-			resources.Secrets = append(resources.Secrets, secret)
+		}
 
+		if err := parser.Mutate(parsed, opts); err != nil {
+			return Resources{}, err
+		}
+		name := parser.Qualify(opts, parsed)
+		if validate {
+			if err := parser.Validate(parsed); err != nil {
+				return Resources{}, err
+			}
+		}
+
+		switch typed := parsed.(type) {
+		case *envoy_config_listener.Listener:
+			resources.Listeners = append(resources.Listeners, typed)
+			log.Debugf("ParseResources: Parsed listener %q: %v", name, typed)
+		case *envoy_config_route.RouteConfiguration:
+			resources.Routes = append(resources.Routes, typed)
+			log.Debugf("ParseResources: Parsed route %q: %v", name, typed)
+		case *envoy_config_cluster.Cluster:
+			resources.Clusters = append(resources.Clusters, typed)
+			log.Debugf("ParseResources: Parsed cluster %q: %v", name, typed)
+		case *envoy_config_endpoint.ClusterLoadAssignment:
+			resources.Endpoints = append(resources.Endpoints, typed)
+			log.Debugf("ParseResources: Parsed endpoints for cluster %q: %v", name, typed)
+		case *envoy_config_tls.Secret:
+			resources.Secrets = append(resources.Secrets, typed)
 			log.Debugf("ParseResources: Parsed secret: %s", name)
-
-		default:
-			return Resources{}, fmt.Errorf("Unsupported type: %s", typeURL)
+		case *envoy_config_core.TypedExtensionConfig:
+			resources.ExtensionConfigs = append(resources.ExtensionConfigs, typed)
+			log.Debugf("ParseResources: Parsed extension config %q: %v", name, typed)
 		}
 	}
 
@@ -476,6 +399,41 @@ func injectCiliumL7Filter(hcmConfig *envoy_config_http.HttpConnectionManager) bo
 	return false
 }
 
+// selectiveRevert accumulates revert funcs for only the resources in a batch that actually NACKed,
+// so a caller can undo just what Envoy rejected instead of reverting every resource in the call.
+type selectiveRevert struct {
+	mutex  lock.Mutex
+	revert xds.AckingResourceMutatorRevertFuncList
+}
+
+// wrap queues the revert func sent on 'revertCh' (a channel, not the func itself, since the upsert/delete
+// call hasn't returned - and so hasn't sent it - by the time this callback is constructed and may run on
+// the xDS stream's own goroutine) for a later Revert, calls 'revertDelta' too, then chains to 'next'.
+func (s *selectiveRevert) wrap(revertCh <-chan xds.AckingResourceMutatorRevertFunc, revertDelta func(), next func(error)) func(error) {
+	return func(err error) {
+		if err != nil {
+			revert := <-revertCh
+			s.mutex.Lock()
+			s.revert = append(s.revert, revert)
+			s.mutex.Unlock()
+			if revertDelta != nil {
+				revertDelta()
+			}
+		}
+		if next != nil {
+			next(err)
+		}
+	}
+}
+
+// Revert undoes every resource that NACKed since selectiveRevert was
+// created, leaving every resource Envoy has already acked untouched.
+func (s *selectiveRevert) Revert(wg *completion.WaitGroup) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.revert.Revert(wg)
+}
+
 func (s *xdsServer) UpsertEnvoyResources(ctx context.Context, resources Resources) error {
 	if option.Config.Debug {
 		msg := ""
@@ -498,6 +456,10 @@ func (s *xdsServer) UpsertEnvoyResources(ctx context.Context, resources Resource
 		}
 		if len(resources.Secrets) > 0 {
 			msg += fmt.Sprintf("%s%d secrets", sep, len(resources.Secrets))
+			sep = ", "
+		}
+		if len(resources.ExtensionConfigs) > 0 {
+			msg += fmt.Sprintf("%s%d extension configs", sep, len(resources.ExtensionConfigs))
 		}
 
 		log.Debugf("UpsertEnvoyResources: Upserting %s...", msg)
@@ -510,7 +472,6 @@ func (s *xdsServer) UpsertEnvoyResources(ctx context.Context, resources Resource
 	if len(resources.Listeners) > 0 && len(resources.Clusters) > 0 {
 		wg = completion.NewWaitGroup(ctx)
 	}
-	var revertFuncs xds.AckingResourceMutatorRevertFuncList
 	// Do not wait for the addition of routes, clusters, endpoints, routes,
 	// or secrets as there are no guarantees that these additions will be
 	// acked. For example, if the listener referring to was already deleted
@@ -521,19 +482,36 @@ func (s *xdsServer) UpsertEnvoyResources(ctx context.Context, resources Resource
 	// If both listeners and clusters are added then wait for clusters.
 	for _, r := range resources.Secrets {
 		log.Debugf("Envoy upsertSecret %s", r.Name)
-		revertFuncs = append(revertFuncs, s.upsertSecret(r.Name, r, nil, nil))
+		updateDeltaCache(SecretTypeURL, r.Name, r)
+		s.upsertSecret(r.Name, r, nil, nil)
+	}
+	for _, r := range resources.ExtensionConfigs {
+		log.Debugf("Envoy upsertExtensionConfig %s", r.Name)
+		updateDeltaCache(ExtensionConfigTypeURL, r.Name, r)
+		s.upsertExtensionConfig(r.Name, r, nil, nil)
 	}
 	for _, r := range resources.Endpoints {
 		log.Debugf("Envoy upsertEndpoint %s %v", r.ClusterName, r)
-		revertFuncs = append(revertFuncs, s.upsertEndpoint(r.ClusterName, r, nil, nil))
-	}
+		updateDeltaCache(EndpointTypeURL, r.ClusterName, r)
+		s.upsertEndpoint(r.ClusterName, r, nil, nil)
+	}
+	// clusterReverts and listenerReverts each hold only the revert funcs
+	// for resources that NACK within their own wg.Wait() below, so a
+	// single bad cluster or listener does not drop every other resource
+	// upserted in this call - including ones Envoy has already acked -
+	// back to its previous state.
+	var clusterReverts selectiveRevert
 	for _, r := range resources.Clusters {
 		log.Debugf("Envoy upsertCluster %s %v", r.Name, r)
-		revertFuncs = append(revertFuncs, s.upsertCluster(r.Name, r, wg, nil))
+		revertDelta := deltaCacheRevertFunc(ClusterTypeURL, r.Name)
+		updateDeltaCache(ClusterTypeURL, r.Name, r)
+		revertCh := make(chan xds.AckingResourceMutatorRevertFunc, 1)
+		revertCh <- s.upsertCluster(r.Name, r, wg, clusterReverts.wrap(revertCh, revertDelta, nil))
 	}
 	for _, r := range resources.Routes {
 		log.Debugf("Envoy upsertRoute %s %v", r.Name, r)
-		revertFuncs = append(revertFuncs, s.upsertRoute(r.Name, r, nil, nil))
+		updateDeltaCache(RouteTypeURL, r.Name, r)
+		s.upsertRoute(r.Name, r, nil, nil)
 	}
 	// Wait before new Listeners are added if clusters were also added above.
 	if wg != nil {
@@ -542,9 +520,12 @@ func (s *xdsServer) UpsertEnvoyResources(ctx context.Context, resources Resource
 		err := wg.Wait()
 		log.Debugf("UpsertEnvoyResources: Wait time for cluster updates %v (err: %s)", time.Since(start), err)
 
-		// revert all changes in case of failure
+		// Only revert the clusters that actually NACKed, so secrets,
+		// extension configs, endpoints and clusters Envoy already acked
+		// keep their new state instead of being dropped back to the old
+		// one along with the cluster that failed.
 		if err != nil {
-			revertFuncs.Revert(nil)
+			clusterReverts.Revert(nil)
 			log.Debug("UpsertEnvoyResources: Finished reverting failed xDS transactions")
 			return err
 		}
@@ -555,12 +536,16 @@ func (s *xdsServer) UpsertEnvoyResources(ctx context.Context, resources Resource
 	if len(resources.Listeners) > 0 {
 		wg = completion.NewWaitGroup(ctx)
 	}
+	var listenerReverts selectiveRevert
 	for _, r := range resources.Listeners {
 		log.Debugf("Envoy upsertListener %s %v", r.Name, r)
 		listenerName := r.Name
-		revertFuncs = append(revertFuncs, s.upsertListener(r.Name, r, wg,
+		revertDelta := deltaCacheRevertFunc(ListenerTypeURL, listenerName)
+		updateDeltaCache(ListenerTypeURL, listenerName, r)
+		revertCh := make(chan xds.AckingResourceMutatorRevertFunc, 1)
+		revertCh <- s.upsertListener(r.Name, r, wg,
 			// this callback is not called if there is no change
-			func(err error) {
+			listenerReverts.wrap(revertCh, revertDelta, func(err error) {
 				if err == nil && resources.portAllocationCallbacks[listenerName] != nil {
 					if callbackErr := resources.portAllocationCallbacks[listenerName](ctx); callbackErr != nil {
 						log.WithError(callbackErr).Warn("Failure in port allocation callback")
@@ -574,9 +559,11 @@ func (s *xdsServer) UpsertEnvoyResources(ctx context.Context, resources Resource
 		err := wg.Wait()
 		log.Debugf("UpsertEnvoyResources: Wait time for proxy updates %v (err: %s)", time.Since(start), err)
 
-		// revert all changes in case of failure
+		// Only revert the listeners that actually NACKed; every other
+		// resource upserted in this call (including listeners Envoy did
+		// ack) is left in place.
 		if err != nil {
-			revertFuncs.Revert(nil)
+			listenerReverts.Revert(nil)
 			log.Debug("UpsertEnvoyResources: Finished reverting failed xDS transactions")
 		}
 		return err
@@ -584,34 +571,71 @@ func (s *xdsServer) UpsertEnvoyResources(ctx context.Context, resources Resource
 	return nil
 }
 
+// byName indexes 'items' by the name 'nameOf' extracts from each, so the
+// per-kind diffs in UpdateEnvoyResources can look resources up in O(1)
+// instead of re-scanning the other slice for every item.
+func byName[T proto.Message](items []T, nameOf func(T) string) map[string]T {
+	m := make(map[string]T, len(items))
+	for _, item := range items {
+		m[nameOf(item)] = item
+	}
+	return m
+}
+
+// removedFrom returns the items of 'oldByName' whose name is absent from
+// 'newByName', i.e. what UpdateEnvoyResources must delete. Both maps are
+// already indexed by name, so this is a single O(n) pass rather than the
+// O(n·m) nested loop it replaces.
+func removedFrom[T proto.Message](oldByName, newByName map[string]T) []T {
+	var removed []T
+	for name, item := range oldByName {
+		if _, ok := newByName[name]; !ok {
+			removed = append(removed, item)
+		}
+	}
+	return removed
+}
+
+// unchanged reports whether 'a' and 'b' marshal to byte-identical wire
+// representations, the same content-hash-equivalent check
+// xds.DeltaCache.Update uses to tell a genuine change from a no-op
+// reconcile. UpdateEnvoyResources uses it to skip re-upserting (and
+// thereby re-pushing to Envoy) a resource that did not actually change.
+func unchanged(a, b proto.Message) bool {
+	da, errA := proto.Marshal(a)
+	db, errB := proto.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return bytes.Equal(da, db)
+}
+
 func (s *xdsServer) UpdateEnvoyResources(ctx context.Context, old, new Resources) error {
 	waitForDelete := false
 	var wg *completion.WaitGroup
-	var revertFuncs xds.AckingResourceMutatorRevertFuncList
 	// Wait only if new Listeners are added, as they will always be acked.
 	// (unreferenced routes or endpoints (and maybe clusters) are not ACKed or NACKed).
 	if len(new.Listeners) > 0 {
 		wg = completion.NewWaitGroup(ctx)
 	}
 	// Delete old listeners not added in 'new' or if old and new listener have different ports
+	oldListenersByName := byName(old.Listeners, func(l *envoy_config_listener.Listener) string { return l.Name })
+	newListenersByName := byName(new.Listeners, func(l *envoy_config_listener.Listener) string { return l.Name })
 	var deleteListeners []*envoy_config_listener.Listener
 	for _, oldListener := range old.Listeners {
-		found := false
 		port := uint32(0)
 		if addr := oldListener.Address.GetSocketAddress(); addr != nil {
 			port = addr.GetPortValue()
 		}
-		for _, newListener := range new.Listeners {
-			if newListener.Name == oldListener.Name {
-				if addr := newListener.Address.GetSocketAddress(); addr != nil && addr.GetPortValue() != port {
-					log.Debugf("UpdateEnvoyResources: %s port changing from %d to %d...", newListener.Name, port, addr.GetPortValue())
-					waitForDelete = true
-				} else {
-					// port is not changing, remove from new.PortAllocations to prevent acking an already acked port.
-					delete(new.portAllocationCallbacks, newListener.Name)
-					found = true
-				}
-				break
+		newListener, found := newListenersByName[oldListener.Name]
+		if found {
+			if addr := newListener.Address.GetSocketAddress(); addr != nil && addr.GetPortValue() != port {
+				log.Debugf("UpdateEnvoyResources: %s port changing from %d to %d...", newListener.Name, port, addr.GetPortValue())
+				waitForDelete = true
+				found = false
+			} else {
+				// port is not changing, remove from new.PortAllocations to prevent acking an already acked port.
+				delete(new.portAllocationCallbacks, newListener.Name)
 			}
 		}
 		if !found {
@@ -621,14 +645,15 @@ func (s *xdsServer) UpdateEnvoyResources(ctx context.Context, old, new Resources
 	log.Debugf("UpdateEnvoyResources: Deleting %d, Upserting %d listeners...", len(deleteListeners), len(new.Listeners))
 	for _, listener := range deleteListeners {
 		listenerName := listener.Name
-		revertFuncs = append(revertFuncs, s.deleteListener(listener.Name, wg,
+		deleteDeltaCache(ListenerTypeURL, listenerName)
+		s.deleteListener(listener.Name, wg,
 			func(err error) {
 				if err == nil && old.portAllocationCallbacks[listenerName] != nil {
 					if callbackErr := old.portAllocationCallbacks[listenerName](ctx); callbackErr != nil {
 						log.WithError(callbackErr).Warn("Failure in port allocation callback")
 					}
 				}
-			}))
+			})
 	}
 
 	// Do not wait for the deletion of routes, clusters, endpoints, or
@@ -640,75 +665,53 @@ func (s *xdsServer) UpdateEnvoyResources(ctx context.Context, old, new Resources
 	// resources to begin with.
 
 	// Delete old routes not added in 'new'
-	var deleteRoutes []*envoy_config_route.RouteConfiguration
-	for _, oldRoute := range old.Routes {
-		found := false
-		for _, newRoute := range new.Routes {
-			if newRoute.Name == oldRoute.Name {
-				found = true
-			}
-		}
-		if !found {
-			deleteRoutes = append(deleteRoutes, oldRoute)
-		}
-	}
+	oldRoutesByName := byName(old.Routes, func(r *envoy_config_route.RouteConfiguration) string { return r.Name })
+	newRoutesByName := byName(new.Routes, func(r *envoy_config_route.RouteConfiguration) string { return r.Name })
+	deleteRoutes := removedFrom(oldRoutesByName, newRoutesByName)
 	log.Debugf("UpdateEnvoyResources: Deleting %d, Upserting %d routes...", len(deleteRoutes), len(new.Routes))
 	for _, route := range deleteRoutes {
-		revertFuncs = append(revertFuncs, s.deleteRoute(route.Name, nil, nil))
+		deleteDeltaCache(RouteTypeURL, route.Name)
+		s.deleteRoute(route.Name, nil, nil)
 	}
 
 	// Delete old clusters not added in 'new'
-	var deleteClusters []*envoy_config_cluster.Cluster
-	for _, oldCluster := range old.Clusters {
-		found := false
-		for _, newCluster := range new.Clusters {
-			if newCluster.Name == oldCluster.Name {
-				found = true
-			}
-		}
-		if !found {
-			deleteClusters = append(deleteClusters, oldCluster)
-		}
-	}
+	oldClustersByName := byName(old.Clusters, func(c *envoy_config_cluster.Cluster) string { return c.Name })
+	newClustersByName := byName(new.Clusters, func(c *envoy_config_cluster.Cluster) string { return c.Name })
+	deleteClusters := removedFrom(oldClustersByName, newClustersByName)
 	log.Debugf("UpdateEnvoyResources: Deleting %d, Upserting %d clusters...", len(deleteClusters), len(new.Clusters))
 	for _, cluster := range deleteClusters {
-		revertFuncs = append(revertFuncs, s.deleteCluster(cluster.Name, nil, nil))
+		deleteDeltaCache(ClusterTypeURL, cluster.Name)
+		s.deleteCluster(cluster.Name, nil, nil)
 	}
 
 	// Delete old endpoints not added in 'new'
-	var deleteEndpoints []*envoy_config_endpoint.ClusterLoadAssignment
-	for _, oldEndpoint := range old.Endpoints {
-		found := false
-		for _, newEndpoint := range new.Endpoints {
-			if newEndpoint.ClusterName == oldEndpoint.ClusterName {
-				found = true
-			}
-		}
-		if !found {
-			deleteEndpoints = append(deleteEndpoints, oldEndpoint)
-		}
-	}
+	oldEndpointsByName := byName(old.Endpoints, func(e *envoy_config_endpoint.ClusterLoadAssignment) string { return e.ClusterName })
+	newEndpointsByName := byName(new.Endpoints, func(e *envoy_config_endpoint.ClusterLoadAssignment) string { return e.ClusterName })
+	deleteEndpoints := removedFrom(oldEndpointsByName, newEndpointsByName)
 	log.Debugf("UpdateEnvoyResources: Deleting %d, Upserting %d endpoints...", len(deleteEndpoints), len(new.Endpoints))
 	for _, endpoint := range deleteEndpoints {
-		revertFuncs = append(revertFuncs, s.deleteEndpoint(endpoint.ClusterName, nil, nil))
+		deleteDeltaCache(EndpointTypeURL, endpoint.ClusterName)
+		s.deleteEndpoint(endpoint.ClusterName, nil, nil)
 	}
 
 	// Delete old secrets not added in 'new'
-	var deleteSecrets []*envoy_config_tls.Secret
-	for _, oldSecret := range old.Secrets {
-		found := false
-		for _, newSecret := range new.Secrets {
-			if newSecret.Name == oldSecret.Name {
-				found = true
-			}
-		}
-		if !found {
-			deleteSecrets = append(deleteSecrets, oldSecret)
-		}
-	}
+	oldSecretsByName := byName(old.Secrets, func(s *envoy_config_tls.Secret) string { return s.Name })
+	newSecretsByName := byName(new.Secrets, func(s *envoy_config_tls.Secret) string { return s.Name })
+	deleteSecrets := removedFrom(oldSecretsByName, newSecretsByName)
 	log.Debugf("UpdateEnvoyResources: Deleting %d, Upserting %d secrets...", len(deleteSecrets), len(new.Secrets))
 	for _, secret := range deleteSecrets {
-		revertFuncs = append(revertFuncs, s.deleteSecret(secret.Name, nil, nil))
+		deleteDeltaCache(SecretTypeURL, secret.Name)
+		s.deleteSecret(secret.Name, nil, nil)
+	}
+
+	// Delete old extension configs not added in 'new'
+	oldExtensionConfigsByName := byName(old.ExtensionConfigs, func(e *envoy_config_core.TypedExtensionConfig) string { return e.Name })
+	newExtensionConfigsByName := byName(new.ExtensionConfigs, func(e *envoy_config_core.TypedExtensionConfig) string { return e.Name })
+	deleteExtensionConfigs := removedFrom(oldExtensionConfigsByName, newExtensionConfigsByName)
+	log.Debugf("UpdateEnvoyResources: Deleting %d, Upserting %d extension configs...", len(deleteExtensionConfigs), len(new.ExtensionConfigs))
+	for _, extensionConfig := range deleteExtensionConfigs {
+		deleteDeltaCache(ExtensionConfigTypeURL, extensionConfig.Name)
+		s.deleteExtensionConfig(extensionConfig.Name, nil, nil)
 	}
 
 	// Have to wait for deletes to complete before adding new listeners if a listener's port number is changed.
@@ -724,21 +727,70 @@ func (s *xdsServer) UpdateEnvoyResources(ctx context.Context, old, new Resources
 		wg = completion.NewWaitGroup(ctx)
 	}
 
-	// Add new Secrets
+	// Add new Secrets, skipping any whose content is byte-identical to the
+	// one it replaces so an unchanged secret does not generate a redundant
+	// xDS push.
 	for _, r := range new.Secrets {
-		revertFuncs = append(revertFuncs, s.upsertSecret(r.Name, r, nil, nil))
+		if prev, ok := oldSecretsByName[r.Name]; ok && unchanged(prev, r) {
+			continue
+		}
+		updateDeltaCache(SecretTypeURL, r.Name, r)
+		s.upsertSecret(r.Name, r, nil, nil)
 	}
-	// Add new Endpoints
+	// Add new ExtensionConfigs, same unchanged-skip as Secrets above.
+	for _, r := range new.ExtensionConfigs {
+		if prev, ok := oldExtensionConfigsByName[r.Name]; ok && unchanged(prev, r) {
+			continue
+		}
+		updateDeltaCache(ExtensionConfigTypeURL, r.Name, r)
+		s.upsertExtensionConfig(r.Name, r, nil, nil)
+	}
+	// Add new Endpoints, same unchanged-skip as Secrets above.
 	for _, r := range new.Endpoints {
-		revertFuncs = append(revertFuncs, s.upsertEndpoint(r.ClusterName, r, nil, nil))
+		if prev, ok := oldEndpointsByName[r.ClusterName]; ok && unchanged(prev, r) {
+			continue
+		}
+		updateDeltaCache(EndpointTypeURL, r.ClusterName, r)
+		s.upsertEndpoint(r.ClusterName, r, nil, nil)
 	}
-	// Add new Clusters
+	// Add new Clusters, same unchanged-skip as Secrets above. clusterReverts
+	// holds only the revert funcs for clusters that actually NACK below, so
+	// a single bad cluster does not revert every other cluster this call
+	// already pushed successfully.
+	var clusterReverts selectiveRevert
 	for _, r := range new.Clusters {
-		revertFuncs = append(revertFuncs, s.upsertCluster(r.Name, r, wg, nil))
+		if prev, ok := oldClustersByName[r.Name]; ok && unchanged(prev, r) {
+			continue
+		}
+		revertDelta := deltaCacheRevertFunc(ClusterTypeURL, r.Name)
+		updateDeltaCache(ClusterTypeURL, r.Name, r)
+		revertCh := make(chan xds.AckingResourceMutatorRevertFunc, 1)
+		revertCh <- s.upsertCluster(r.Name, r, wg, clusterReverts.wrap(revertCh, revertDelta, nil))
+
+		// Re-push the Endpoint too (envoyproxy/envoy#13009, see cascadeChildRepush)
+		// even though the unchanged-skip above left it untouched.
+		if lister, ok := resourceParsers[ClusterTypeURL].(ChildResourceLister); ok {
+			for _, name := range lister.ChildResources(r)[EndpointTypeURL] {
+				endpoint, ok := newEndpointsByName[name]
+				if !ok {
+					continue
+				}
+				prevEndpoint, wasOld := oldEndpointsByName[name]
+				if !wasOld || !unchanged(prevEndpoint, endpoint) {
+					continue // already pushed above, either new or genuinely changed
+				}
+				updateDeltaCache(EndpointTypeURL, name, endpoint)
+				s.upsertEndpoint(name, endpoint, nil, nil)
+			}
+		}
 	}
-	// Add new Routes
+	// Add new Routes, same unchanged-skip as Secrets above.
 	for _, r := range new.Routes {
-		revertFuncs = append(revertFuncs, s.upsertRoute(r.Name, r, nil, nil))
+		if prev, ok := oldRoutesByName[r.Name]; ok && unchanged(prev, r) {
+			continue
+		}
+		updateDeltaCache(RouteTypeURL, r.Name, r)
+		s.upsertRoute(r.Name, r, nil, nil)
 	}
 	if wg != nil && len(new.Clusters) > 0 {
 		start := time.Now()
@@ -746,23 +798,64 @@ func (s *xdsServer) UpdateEnvoyResources(ctx context.Context, old, new Resources
 		err := wg.Wait()
 		if err != nil {
 			log.Debug("UpdateEnvoyResources: cluster update failed: ", err)
+			// Only revert the clusters that actually NACKed; routes,
+			// endpoints, secrets and clusters Envoy already acked keep
+			// their new state.
+			clusterReverts.Revert(nil)
 		}
 		log.Debug("UpdateEnvoyResources: Wait time for cluster updates: ", time.Since(start))
 		// new wait group for adds
 		wg = completion.NewWaitGroup(ctx)
 	}
-	// Add new Listeners
+	// Add new Listeners. listenerReverts holds only the revert funcs for
+	// listeners that actually NACK below, so a single bad listener (e.g.
+	// one referencing a route config Envoy rejects) does not revert every
+	// other resource upserted in this call - including the routes,
+	// clusters, endpoints, secrets and listeners Envoy already acked -
+	// back to its previous state.
+	var listenerReverts selectiveRevert
 	for _, r := range new.Listeners {
+		// Same unchanged-skip as Secrets above: a Listener byte-identical
+		// to the one it replaces needs neither re-upserting nor a cascade
+		// re-push of its routes, since Envoy never discarded anything for
+		// it in the first place.
+		prev, wasOld := oldListenersByName[r.Name]
+		if wasOld && unchanged(prev, r) {
+			continue
+		}
+
 		listenerName := r.Name
-		revertFuncs = append(revertFuncs, s.upsertListener(r.Name, r, wg,
+		revertDelta := deltaCacheRevertFunc(ListenerTypeURL, listenerName)
+		updateDeltaCache(ListenerTypeURL, listenerName, r)
+		revertCh := make(chan xds.AckingResourceMutatorRevertFunc, 1)
+		revertCh <- s.upsertListener(r.Name, r, wg,
 			// this callback is not called if there is no change
-			func(err error) {
+			listenerReverts.wrap(revertCh, revertDelta, func(err error) {
 				if err == nil && new.portAllocationCallbacks[listenerName] != nil {
 					if callbackErr := new.portAllocationCallbacks[listenerName](ctx); callbackErr != nil {
 						log.WithError(callbackErr).Warn("Failure in port allocation callback")
 					}
 				}
 			}))
+
+		// Re-push the Route too (envoyproxy/envoy#13009, see cascadeChildRepush).
+		// A brand new listener has no 'prev' to cascade from, hence wasOld.
+		if wasOld {
+			if lister, ok := resourceParsers[ListenerTypeURL].(ChildResourceLister); ok {
+				for _, name := range lister.ChildResources(r)[RouteTypeURL] {
+					route, ok := newRoutesByName[name]
+					if !ok {
+						continue
+					}
+					prevRoute, wasOldRoute := oldRoutesByName[name]
+					if !wasOldRoute || !unchanged(prevRoute, route) {
+						continue // already pushed above, either new or genuinely changed
+					}
+					updateDeltaCache(RouteTypeURL, name, route)
+					s.upsertRoute(name, route, nil, nil)
+				}
+			}
+		}
 	}
 
 	if wg != nil {
@@ -771,9 +864,11 @@ func (s *xdsServer) UpdateEnvoyResources(ctx context.Context, old, new Resources
 		err := wg.Wait()
 		log.Debugf("UpdateEnvoyResources: Wait time for proxy updates %v (err: %s)", time.Since(start), err)
 
-		// revert all changes in case of failure
+		// Only revert the listeners that actually NACKed; every other
+		// resource this call deleted or upserted (including listeners
+		// Envoy did ack) is left in place.
 		if err != nil {
-			revertFuncs.Revert(nil)
+			listenerReverts.Revert(nil)
 			log.Debug("UpdateEnvoyResources: Finished reverting failed xDS transactions")
 		}
 		return err
@@ -782,8 +877,8 @@ func (s *xdsServer) UpdateEnvoyResources(ctx context.Context, old, new Resources
 }
 
 func (s *xdsServer) DeleteEnvoyResources(ctx context.Context, resources Resources) error {
-	log.Debugf("DeleteEnvoyResources: Deleting %d listeners, %d routes, %d clusters, %d endpoints, and %d secrets...",
-		len(resources.Listeners), len(resources.Routes), len(resources.Clusters), len(resources.Endpoints), len(resources.Secrets))
+	log.Debugf("DeleteEnvoyResources: Deleting %d listeners, %d routes, %d clusters, %d endpoints, %d secrets, and %d extension configs...",
+		len(resources.Listeners), len(resources.Routes), len(resources.Clusters), len(resources.Endpoints), len(resources.Secrets), len(resources.ExtensionConfigs))
 	var wg *completion.WaitGroup
 	var revertFuncs xds.AckingResourceMutatorRevertFuncList
 	// Wait only if new Listeners are added, as they will always be acked.
@@ -793,6 +888,7 @@ func (s *xdsServer) DeleteEnvoyResources(ctx context.Context, resources Resource
 	}
 	for _, r := range resources.Listeners {
 		listenerName := r.Name
+		deleteDeltaCache(ListenerTypeURL, listenerName)
 		revertFuncs = append(revertFuncs, s.deleteListener(r.Name, wg,
 			func(err error) {
 				if err == nil && resources.portAllocationCallbacks[listenerName] != nil {
@@ -811,17 +907,25 @@ func (s *xdsServer) DeleteEnvoyResources(ctx context.Context, resources Resource
 	// there is no listener referring to other named resources to
 	// begin with.
 	for _, r := range resources.Routes {
+		deleteDeltaCache(RouteTypeURL, r.Name)
 		revertFuncs = append(revertFuncs, s.deleteRoute(r.Name, nil, nil))
 	}
 	for _, r := range resources.Clusters {
+		deleteDeltaCache(ClusterTypeURL, r.Name)
 		revertFuncs = append(revertFuncs, s.deleteCluster(r.Name, nil, nil))
 	}
 	for _, r := range resources.Endpoints {
+		deleteDeltaCache(EndpointTypeURL, r.ClusterName)
 		revertFuncs = append(revertFuncs, s.deleteEndpoint(r.ClusterName, nil, nil))
 	}
 	for _, r := range resources.Secrets {
+		deleteDeltaCache(SecretTypeURL, r.Name)
 		revertFuncs = append(revertFuncs, s.deleteSecret(r.Name, nil, nil))
 	}
+	for _, r := range resources.ExtensionConfigs {
+		deleteDeltaCache(ExtensionConfigTypeURL, r.Name)
+		revertFuncs = append(revertFuncs, s.deleteExtensionConfig(r.Name, nil, nil))
+	}
 
 	if wg != nil {
 		start := time.Now()
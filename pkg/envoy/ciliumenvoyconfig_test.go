@@ -0,0 +1,81 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package envoy
+
+import (
+	"testing"
+
+	envoy_config_cluster "github.com/cilium/proxy/go/envoy/config/cluster/v3"
+
+	"github.com/cilium/cilium/pkg/option"
+)
+
+func withEnvoyDeltaXDS(t *testing.T, enabled bool) {
+	t.Helper()
+	prev := option.Config.EnableEnvoyDeltaXDS
+	option.Config.EnableEnvoyDeltaXDS = enabled
+	t.Cleanup(func() { option.Config.EnableEnvoyDeltaXDS = prev })
+}
+
+func TestDeltaCacheRevertFuncRestoresPriorValue(t *testing.T) {
+	withEnvoyDeltaXDS(t, true)
+	cache := deltaCaches[ClusterTypeURL]
+	name := "test-namespace/test-cec/cluster"
+
+	old := &envoy_config_cluster.Cluster{Name: name, AltStatName: "old"}
+	if _, _, err := cache.Update(name, old); err != nil {
+		t.Fatalf("seed Update: %v", err)
+	}
+
+	revert := deltaCacheRevertFunc(ClusterTypeURL, name)
+	if _, _, err := cache.Update(name, &envoy_config_cluster.Cluster{Name: name, AltStatName: "rejected"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	revert()
+
+	resource, _, ok := cache.Get(name)
+	if !ok {
+		t.Fatal("expected reverted entry to still be present")
+	}
+	if got := resource.(*envoy_config_cluster.Cluster).AltStatName; got != "old" {
+		t.Fatalf("expected revert to restore the pre-update resource, got AltStatName %q", got)
+	}
+}
+
+func TestDeltaCacheRevertFuncDeletesWhenNoPriorValue(t *testing.T) {
+	withEnvoyDeltaXDS(t, true)
+	cache := deltaCaches[ClusterTypeURL]
+	name := "test-namespace/test-cec/new-cluster"
+	cache.Delete(name)
+
+	revert := deltaCacheRevertFunc(ClusterTypeURL, name)
+	if _, _, err := cache.Update(name, &envoy_config_cluster.Cluster{Name: name}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	revert()
+
+	if _, _, ok := cache.Get(name); ok {
+		t.Fatal("expected revert to remove a resource that had no prior version")
+	}
+}
+
+func TestDeltaCacheRevertFuncNoopWhenDeltaXDSDisabled(t *testing.T) {
+	withEnvoyDeltaXDS(t, false)
+	cache := deltaCaches[ClusterTypeURL]
+	name := "test-namespace/test-cec/disabled-cluster"
+	cache.Delete(name)
+
+	revert := deltaCacheRevertFunc(ClusterTypeURL, name)
+	if _, _, err := cache.Update(name, &envoy_config_cluster.Cluster{Name: name}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	revert()
+
+	if _, _, ok := cache.Get(name); !ok {
+		t.Fatal("expected revert to be a no-op while delta xDS is disabled, leaving the direct Update in place")
+	}
+}
@@ -0,0 +1,194 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package envoy
+
+import (
+	"fmt"
+	"sync"
+
+	envoy_service_discovery "github.com/cilium/proxy/go/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/types/known/anypb"
+
+	"github.com/cilium/cilium/pkg/envoy/xds"
+)
+
+// DeltaAggregatedResources implements the Delta/Incremental ADS transport alongside
+// StreamAggregatedResources (SotW); an Envoy picks one simply by which RPC it calls.
+func (s *xdsServer) DeltaAggregatedResources(stream envoy_service_discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
+	session := newDeltaSession(stream)
+	defer session.stop()
+	return session.run()
+}
+
+// deltaSession is the per-stream state for one Envoy connected over Delta xDS: one xds.Subscription
+// per requested TypeURL, and deltaNotifiers wakeups forwarded into a single channel run's loop selects
+// on alongside incoming requests, so resource changes push immediately rather than on the next request.
+type deltaSession struct {
+	stream envoy_service_discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer
+
+	mutex         sync.Mutex
+	subscriptions map[string]*xds.Subscription
+
+	// pushes carries the TypeURL of every DeltaCache change not yet reacted to.
+	pushes chan string
+
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func newDeltaSession(stream envoy_service_discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) *deltaSession {
+	return &deltaSession{
+		stream:        stream,
+		subscriptions: make(map[string]*xds.Subscription),
+		pushes:        make(chan string, len(deltaCaches)),
+		done:          make(chan struct{}),
+	}
+}
+
+// stop tears down this session's deltaNotifiers subscriptions and unregisters its SecretTypeURL
+// Subscription from secretSubscriptions; safe to call more than once.
+func (ds *deltaSession) stop() {
+	ds.stopOnce.Do(func() {
+		if sub, ok := ds.subscriptions[SecretTypeURL]; ok {
+			unregisterSecretSubscription(sub)
+		}
+		close(ds.done)
+	})
+}
+
+// run reads DeltaDiscoveryRequests and DeltaCache change notifications until the stream errors,
+// the peer closes it, or its context is canceled.
+func (ds *deltaSession) run() error {
+	ctx := ds.stream.Context()
+
+	requests := make(chan *envoy_service_discovery.DeltaDiscoveryRequest)
+	recvErrs := make(chan error, 1)
+	go func() {
+		for {
+			req, err := ds.stream.Recv()
+			if err != nil {
+				recvErrs <- err
+				return
+			}
+			select {
+			case requests <- req:
+			case <-ds.done:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-recvErrs:
+			return err
+		case req := <-requests:
+			if err := ds.handleRequest(req); err != nil {
+				return err
+			}
+		case typeURL := <-ds.pushes:
+			if err := ds.pushNow(typeURL); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handleRequest applies one DeltaDiscoveryRequest - creating the TypeURL's Subscription on its first
+// request, resolving ACK/NACK and subscribe/unsubscribe on later ones - then pushes immediately.
+func (ds *deltaSession) handleRequest(req *envoy_service_discovery.DeltaDiscoveryRequest) error {
+	typeURL := req.GetTypeUrl()
+
+	ds.mutex.Lock()
+	sub, ok := ds.subscriptions[typeURL]
+	if !ok {
+		sub = xds.NewSubscription(req.GetResourceNamesSubscribe(), req.GetInitialResourceVersions())
+		ds.subscriptions[typeURL] = sub
+	}
+	ds.mutex.Unlock()
+
+	if !ok {
+		if typeURL == SecretTypeURL {
+			registerSecretSubscription(sub)
+		}
+		ds.watch(typeURL)
+	} else {
+		if nonce := req.GetResponseNonce(); nonce != "" {
+			sub.HandleAck(nonce, req.GetErrorDetail() != nil)
+		}
+		sub.Subscribe(req.GetResourceNamesSubscribe(), req.GetResourceNamesUnsubscribe())
+	}
+
+	return ds.pushNow(typeURL)
+}
+
+// watch subscribes to deltaNotifiers[typeURL] and forwards wakeups into ds.pushes until the session stops.
+func (ds *deltaSession) watch(typeURL string) {
+	notifier := deltaNotifiers[typeURL]
+	if notifier == nil {
+		return
+	}
+	ch, cancel := notifier.Subscribe()
+	go func() {
+		defer cancel()
+		for {
+			select {
+			case <-ch:
+				select {
+				case ds.pushes <- typeURL:
+				case <-ds.done:
+					return
+				}
+			case <-ds.done:
+				return
+			}
+		}
+	}()
+}
+
+// pushNow sends the DeltaDiscoveryResponse Subscription.NextResponse computes for 'typeURL' (which may be
+// the empty first response to a wildcard subscription), or nothing when it returns no nonce.
+func (ds *deltaSession) pushNow(typeURL string) error {
+	ds.mutex.Lock()
+	sub := ds.subscriptions[typeURL]
+	ds.mutex.Unlock()
+	if sub == nil {
+		return nil
+	}
+	cache := deltaCaches[typeURL]
+	if cache == nil {
+		return nil
+	}
+
+	nonce, updated, removed := sub.NextResponse(cache)
+	if nonce == "" {
+		return nil
+	}
+
+	resources := make([]*envoy_service_discovery.Resource, 0, len(updated))
+	for name, msg := range updated {
+		_, version, ok := cache.Get(name)
+		if !ok {
+			continue
+		}
+		any, err := anypb.New(msg)
+		if err != nil {
+			return fmt.Errorf("marshal %s %q: %w", typeURL, name, err)
+		}
+		resources = append(resources, &envoy_service_discovery.Resource{
+			Name:     name,
+			Version:  string(version),
+			Resource: any,
+		})
+	}
+
+	return ds.stream.Send(&envoy_service_discovery.DeltaDiscoveryResponse{
+		TypeUrl:          typeURL,
+		Nonce:            nonce,
+		Resources:        resources,
+		RemovedResources: removed,
+	})
+}
@@ -0,0 +1,109 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package envoy
+
+import (
+	"fmt"
+
+	envoy_config_core "github.com/cilium/proxy/go/envoy/config/core/v3"
+	envoy_config_listener "github.com/cilium/proxy/go/envoy/config/listener/v3"
+	envoy_config_http "github.com/cilium/proxy/go/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/cilium/cilium/pkg/envoy/xds"
+	"github.com/cilium/cilium/pkg/policy/api"
+)
+
+// ExtensionConfigTypeURL is the TypeURL for resources delivered over the
+// Extension Config Discovery Service, i.e. HTTP/network filter configs
+// that can be updated independently of the Listener that references them.
+const ExtensionConfigTypeURL = "type.googleapis.com/envoy.config.core.v3.TypedExtensionConfig"
+
+func init() {
+	deltaCaches[ExtensionConfigTypeURL] = xds.NewDeltaCache()
+	deltaNotifiers[ExtensionConfigTypeURL] = xds.NewBroadcaster()
+	RegisterResourceParser(ExtensionConfigTypeURL, extensionConfigParser{})
+}
+
+// extensionConfigParser implements ResourceParser for TypedExtensionConfig
+// resources delivered via ECDS, e.g. WASM, ext_authz, or RBAC filter
+// configs that should live-update without churning the whole Listener.
+type extensionConfigParser struct{}
+
+func (extensionConfigParser) Parse(message proto.Message) (proto.Message, error) {
+	config, ok := message.(*envoy_config_core.TypedExtensionConfig)
+	if !ok {
+		return nil, fmt.Errorf("Invalid type for TypedExtensionConfig: %T", message)
+	}
+	if config.Name == "" {
+		return nil, fmt.Errorf("TypedExtensionConfig name not provided")
+	}
+	return config, nil
+}
+
+func (extensionConfigParser) Mutate(msg proto.Message, opts ParseOptions) error {
+	return nil
+}
+
+func (extensionConfigParser) Qualify(opts ParseOptions, msg proto.Message) string {
+	config := msg.(*envoy_config_core.TypedExtensionConfig)
+	name := config.Name
+	config.Name, _ = api.ResourceQualifiedName(opts.CECNamespace, opts.CECName, name)
+	return name
+}
+
+func (extensionConfigParser) Validate(msg proto.Message) error {
+	config := msg.(*envoy_config_core.TypedExtensionConfig)
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("ParseResources: Could not validate TypedExtensionConfig %q (%s): %s", config.Name, err, config.String())
+	}
+	return nil
+}
+
+// qualifyExtensionConfigSource qualifies the ECDS resource name a
+// ConfigDiscovery-typed filter refers to, mirroring how HCM's
+// Rds.RouteConfigName is qualified in listenerParser.Mutate, and defaults its ConfigSource to
+// ciliumXDS when the filter author left it unset.
+func qualifyExtensionConfigSource(namespace, name string, filterName string, source *envoy_config_core.ExtensionConfigSource) (qualifiedName string, updated bool) {
+	qualifiedName, updated = api.ResourceQualifiedName(namespace, name, filterName, api.ForceNamespace)
+	if source.ConfigSource == nil {
+		source.ConfigSource = ciliumXDS
+		updated = true
+	}
+	return qualifiedName, updated
+}
+
+// qualifyListenerFilterExtensionConfigs walks a listener filter chain's
+// network filters and, for every filter delivered via ECDS
+// (ConfigType == ConfigDiscovery), qualifies the extension config name it
+// refers to and fills in its ConfigSource if unset.
+func qualifyListenerFilterExtensionConfigs(namespace, name string, filters []*envoy_config_listener.Filter) {
+	for _, filter := range filters {
+		discovery, ok := filter.GetConfigType().(*envoy_config_listener.Filter_ConfigDiscovery)
+		if !ok || discovery.ConfigDiscovery == nil {
+			continue
+		}
+		if qualifiedName, updated := qualifyExtensionConfigSource(namespace, name, filter.Name, discovery.ConfigDiscovery); updated {
+			filter.Name = qualifiedName
+		}
+	}
+}
+
+// qualifyHttpFilterExtensionConfigs does the same as
+// qualifyListenerFilterExtensionConfigs, for HTTP filters configured on an
+// HttpConnectionManager.
+func qualifyHttpFilterExtensionConfigs(namespace, name string, httpFilters []*envoy_config_http.HttpFilter) bool {
+	updated := false
+	for _, httpFilter := range httpFilters {
+		discovery, ok := httpFilter.GetConfigType().(*envoy_config_http.HttpFilter_ConfigDiscovery)
+		if !ok || discovery.ConfigDiscovery == nil {
+			continue
+		}
+		if qualifiedName, filterUpdated := qualifyExtensionConfigSource(namespace, name, httpFilter.Name, discovery.ConfigDiscovery); filterUpdated {
+			httpFilter.Name = qualifiedName
+			updated = true
+		}
+	}
+	return updated
+}
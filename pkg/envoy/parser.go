@@ -0,0 +1,420 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package envoy
+
+import (
+	"fmt"
+
+	cilium "github.com/cilium/proxy/go/cilium/api"
+	envoy_config_cluster "github.com/cilium/proxy/go/envoy/config/cluster/v3"
+	envoy_config_endpoint "github.com/cilium/proxy/go/envoy/config/endpoint/v3"
+	envoy_config_listener "github.com/cilium/proxy/go/envoy/config/listener/v3"
+	envoy_config_route "github.com/cilium/proxy/go/envoy/config/route/v3"
+	envoy_config_http "github.com/cilium/proxy/go/envoy/extensions/filters/network/http_connection_manager/v3"
+	envoy_config_tcp "github.com/cilium/proxy/go/envoy/extensions/filters/network/tcp_proxy/v3"
+	envoy_config_tls "github.com/cilium/proxy/go/envoy/extensions/transport_sockets/tls/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/cilium/cilium/pkg/option"
+	"github.com/cilium/cilium/pkg/policy/api"
+)
+
+// ParseOptions bundles the per-CEC, per-call parameters each ResourceParser needs.
+type ParseOptions struct {
+	CECNamespace          string
+	CECName               string
+	PortAllocator         PortAllocator
+	IsL7LB                bool
+	UseOriginalSourceAddr bool
+}
+
+// ResourceParser handles one Envoy xDS TypeURL end to end: type-asserting, qualifying names,
+// applying Cilium-specific rewrites, and validating. See RegisterResourceParser to add one.
+type ResourceParser interface {
+	// Parse type-asserts 'message' into the concrete proto type this parser handles and checks its name is set.
+	Parse(message proto.Message) (proto.Message, error)
+
+	// Mutate applies Cilium-specific rewrites to 'msg' other than qualifying its own top-level name. Runs before Qualify.
+	Mutate(msg proto.Message, opts ParseOptions) error
+
+	// Qualify prepends the CEC namespace/name to 'msg's own resource name, returning the unqualified name replaced.
+	Qualify(opts ParseOptions, msg proto.Message) (name string)
+
+	// Validate runs the message's generated proto Validate(), wrapping the error with resource/type context.
+	Validate(msg proto.Message) error
+}
+
+// ChildResourceLister is implemented by a ResourceParser whose resource type references named resources of
+// another TypeURL that Envoy discards as a side effect of the parent being updated (envoyproxy/envoy#13009);
+// cascadeChildRepush uses this to force a re-push of exactly those children.
+type ChildResourceLister interface {
+	// ChildResources returns, keyed by child TypeURL, the qualified names 'msg' references, or nil.
+	ChildResources(msg proto.Message) map[string][]string
+}
+
+// resourceParsers holds the default ResourceParser for every TypeURL ParseResources understands.
+// Call RegisterResourceParser to add or replace one instead of patching ParseResources itself.
+var resourceParsers = map[string]ResourceParser{
+	ListenerTypeURL: listenerParser{},
+	RouteTypeURL:    routeParser{},
+	ClusterTypeURL:  clusterParser{},
+	EndpointTypeURL: endpointParser{},
+	SecretTypeURL:   secretParser{},
+}
+
+// RegisterResourceParser registers (or replaces) the ResourceParser used for 'typeURL'.
+func RegisterResourceParser(typeURL string, parser ResourceParser) {
+	resourceParsers[typeURL] = parser
+}
+
+// listenerParser implements ResourceParser for Listener resources.
+type listenerParser struct{}
+
+func (listenerParser) Parse(message proto.Message) (proto.Message, error) {
+	listener, ok := message.(*envoy_config_listener.Listener)
+	if !ok {
+		return nil, fmt.Errorf("Invalid type for Listener: %T", message)
+	}
+	if listener.Name == "" {
+		return nil, fmt.Errorf("'Listener name not provided")
+	}
+	return listener, nil
+}
+
+func (listenerParser) Mutate(msg proto.Message, opts ParseOptions) error {
+	listener := msg.(*envoy_config_listener.Listener)
+
+	if option.Config.EnableBPFTProxy {
+		// Envoy since 1.20.0 uses SO_REUSEPORT on listeners by default.
+		// BPF TPROXY is currently not compatible with SO_REUSEPORT, so
+		// disable it.  Note that this may degrade Envoy performance.
+		listener.EnableReusePort = &wrapperspb.BoolValue{Value: false}
+	}
+
+	// Figure out if this is an internal listener
+	isInternalListener := listener.GetInternalListener() != nil
+
+	// Only inject Cilium filters if Cilium allocates listener address
+	injectCiliumFilters := listener.GetAddress() == nil && !isInternalListener
+
+	// Inject Cilium bpf metadata listener filter, if not already present.
+	if !isInternalListener {
+		found := false
+		for _, lf := range listener.ListenerFilters {
+			if lf.Name == "cilium.bpf_metadata" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			listener.ListenerFilters = append(listener.ListenerFilters, getListenerFilter(false /* egress */, opts.UseOriginalSourceAddr, opts.IsL7LB))
+		}
+	}
+
+	// Fill in SDS & RDS config source if unset
+	for _, fc := range listener.FilterChains {
+		fillInTransportSocketXDS(opts.CECNamespace, opts.CECName, fc.TransportSocket)
+		qualifyListenerFilterExtensionConfigs(opts.CECNamespace, opts.CECName, fc.Filters)
+		foundCiliumNetworkFilter := false
+		for i, filter := range fc.Filters {
+			if filter.Name == "cilium.network" {
+				foundCiliumNetworkFilter = true
+			}
+			tc := filter.GetTypedConfig()
+			if tc == nil {
+				continue
+			}
+			switch tc.GetTypeUrl() {
+			case HttpConnectionManagerTypeURL:
+				any, err := tc.UnmarshalNew()
+				if err != nil {
+					continue
+				}
+				hcmConfig, ok := any.(*envoy_config_http.HttpConnectionManager)
+				if !ok {
+					continue
+				}
+				updated := false
+				if rds := hcmConfig.GetRds(); rds != nil {
+					// Since we are prepending CEC namespace and name to Routes name,
+					// we must do the same here to point to the correct Route resource.
+					if rds.RouteConfigName != "" {
+						rds.RouteConfigName, updated = api.ResourceQualifiedName(opts.CECNamespace, opts.CECName, rds.RouteConfigName, api.ForceNamespace)
+					}
+					if rds.ConfigSource == nil {
+						rds.ConfigSource = ciliumXDS
+						updated = true
+					}
+				}
+				if routeConfig := hcmConfig.GetRouteConfig(); routeConfig != nil {
+					if qualifyRouteConfigurationResourceNames(opts.CECNamespace, opts.CECName, routeConfig) {
+						updated = true
+					}
+				}
+				if injectCiliumFilters {
+					l7FilterUpdated := injectCiliumL7Filter(hcmConfig)
+					updated = updated || l7FilterUpdated
+				}
+				if qualifyHttpFilterExtensionConfigs(opts.CECNamespace, opts.CECName, hcmConfig.HttpFilters) {
+					updated = true
+				}
+				if updated {
+					filter.ConfigType = &envoy_config_listener.Filter_TypedConfig{
+						TypedConfig: toAny(hcmConfig),
+					}
+				}
+			case TCPProxyTypeURL:
+				any, err := tc.UnmarshalNew()
+				if err != nil {
+					continue
+				}
+				tcpProxy, ok := any.(*envoy_config_tcp.TcpProxy)
+				if !ok {
+					continue
+				}
+
+				if qualifyTcpProxyResourceNames(opts.CECNamespace, opts.CECName, tcpProxy) {
+					filter.ConfigType = &envoy_config_listener.Filter_TypedConfig{
+						TypedConfig: toAny(tcpProxy),
+					}
+				}
+			default:
+				continue
+			}
+			if injectCiliumFilters {
+				if !foundCiliumNetworkFilter {
+					// Inject Cilium network filter just before the HTTP Connection Manager or TCPProxy filter
+					fc.Filters = append(fc.Filters[:i+1], fc.Filters[i:]...)
+					fc.Filters[i] = &envoy_config_listener.Filter{
+						Name: "cilium.network",
+						ConfigType: &envoy_config_listener.Filter_TypedConfig{
+							TypedConfig: toAny(&cilium.NetworkFilter{}),
+						},
+					}
+				}
+			}
+			break // Done with this filter chain
+		}
+	}
+
+	return nil
+}
+
+func (listenerParser) Qualify(opts ParseOptions, msg proto.Message) string {
+	listener := msg.(*envoy_config_listener.Listener)
+	name := listener.Name
+	listener.Name, _ = api.ResourceQualifiedName(opts.CECNamespace, opts.CECName, listener.Name, api.ForceNamespace)
+	return name
+}
+
+func (listenerParser) Validate(msg proto.Message) error {
+	listener := msg.(*envoy_config_listener.Listener)
+	if err := listener.Validate(); err != nil {
+		return fmt.Errorf("ParseResources: Could not validate Listener (%s): %s", err, listener.String())
+	}
+	return nil
+}
+
+// ChildResources implements ChildResourceLister: the RouteConfigName of every RDS-resolving HCM filter.
+func (listenerParser) ChildResources(msg proto.Message) map[string][]string {
+	listener := msg.(*envoy_config_listener.Listener)
+	var routes []string
+	for _, fc := range listener.FilterChains {
+		for _, filter := range fc.Filters {
+			tc := filter.GetTypedConfig()
+			if tc == nil || tc.GetTypeUrl() != HttpConnectionManagerTypeURL {
+				continue
+			}
+			any, err := tc.UnmarshalNew()
+			if err != nil {
+				continue
+			}
+			hcmConfig, ok := any.(*envoy_config_http.HttpConnectionManager)
+			if !ok {
+				continue
+			}
+			if rds := hcmConfig.GetRds(); rds != nil && rds.RouteConfigName != "" {
+				routes = append(routes, rds.RouteConfigName)
+			}
+		}
+	}
+	if len(routes) == 0 {
+		return nil
+	}
+	return map[string][]string{RouteTypeURL: routes}
+}
+
+// routeParser implements ResourceParser for RouteConfiguration resources.
+type routeParser struct{}
+
+func (routeParser) Parse(message proto.Message) (proto.Message, error) {
+	route, ok := message.(*envoy_config_route.RouteConfiguration)
+	if !ok {
+		return nil, fmt.Errorf("Invalid type for Route: %T", message)
+	}
+	if route.Name == "" {
+		return nil, fmt.Errorf("RouteConfiguration name not provided")
+	}
+	return route, nil
+}
+
+func (routeParser) Mutate(msg proto.Message, opts ParseOptions) error {
+	route := msg.(*envoy_config_route.RouteConfiguration)
+	qualifyRouteConfigurationResourceNames(opts.CECNamespace, opts.CECName, route)
+	return nil
+}
+
+func (routeParser) Qualify(opts ParseOptions, msg proto.Message) string {
+	route := msg.(*envoy_config_route.RouteConfiguration)
+	name := route.Name
+	route.Name, _ = api.ResourceQualifiedName(opts.CECNamespace, opts.CECName, name, api.ForceNamespace)
+	return name
+}
+
+func (routeParser) Validate(msg proto.Message) error {
+	route := msg.(*envoy_config_route.RouteConfiguration)
+	if err := route.Validate(); err != nil {
+		return fmt.Errorf("ParseResources: Could not validate RouteConfiguration (%s): %s", err, route.String())
+	}
+	return nil
+}
+
+// clusterParser implements ResourceParser for Cluster resources.
+type clusterParser struct{}
+
+func (clusterParser) Parse(message proto.Message) (proto.Message, error) {
+	cluster, ok := message.(*envoy_config_cluster.Cluster)
+	if !ok {
+		return nil, fmt.Errorf("Invalid type for Route: %T", message)
+	}
+	if cluster.Name == "" {
+		return nil, fmt.Errorf("Cluster name not provided")
+	}
+	return cluster, nil
+}
+
+func (clusterParser) Mutate(msg proto.Message, opts ParseOptions) error {
+	cluster := msg.(*envoy_config_cluster.Cluster)
+
+	fillInTransportSocketXDS(opts.CECNamespace, opts.CECName, cluster.TransportSocket)
+
+	// Fill in EDS config source if unset
+	if enum := cluster.GetType(); enum == envoy_config_cluster.Cluster_EDS {
+		if cluster.EdsClusterConfig == nil {
+			cluster.EdsClusterConfig = &envoy_config_cluster.Cluster_EdsClusterConfig{}
+		}
+		if cluster.EdsClusterConfig.EdsConfig == nil {
+			cluster.EdsClusterConfig.EdsConfig = ciliumXDS
+		}
+	}
+
+	if cluster.LoadAssignment != nil {
+		cluster.LoadAssignment.ClusterName, _ = api.ResourceQualifiedName(opts.CECNamespace, opts.CECName, cluster.LoadAssignment.ClusterName)
+	}
+
+	if serviceName := cluster.GetEdsClusterConfig().GetServiceName(); serviceName != "" {
+		cluster.EdsClusterConfig.ServiceName, _ = api.ResourceQualifiedName(opts.CECNamespace, opts.CECName, serviceName)
+	}
+
+	return nil
+}
+
+func (clusterParser) Qualify(opts ParseOptions, msg proto.Message) string {
+	cluster := msg.(*envoy_config_cluster.Cluster)
+	name := cluster.Name
+	cluster.Name, _ = api.ResourceQualifiedName(opts.CECNamespace, opts.CECName, name)
+	return name
+}
+
+func (clusterParser) Validate(msg proto.Message) error {
+	cluster := msg.(*envoy_config_cluster.Cluster)
+	if err := cluster.Validate(); err != nil {
+		return fmt.Errorf("ParseResources: Could not validate Cluster %q (%s): %s", cluster.Name, err, cluster.String())
+	}
+	return nil
+}
+
+// ChildResources implements ChildResourceLister: an EDS Cluster's child is the ClusterLoadAssignment
+// matching its name, or EdsClusterConfig.ServiceName when that overrides it. Non-EDS Clusters have none.
+func (clusterParser) ChildResources(msg proto.Message) map[string][]string {
+	cluster := msg.(*envoy_config_cluster.Cluster)
+	if cluster.GetType() != envoy_config_cluster.Cluster_EDS {
+		return nil
+	}
+	name := cluster.Name
+	if serviceName := cluster.GetEdsClusterConfig().GetServiceName(); serviceName != "" {
+		name = serviceName
+	}
+	if name == "" {
+		return nil
+	}
+	return map[string][]string{EndpointTypeURL: {name}}
+}
+
+// endpointParser implements ResourceParser for ClusterLoadAssignment resources.
+type endpointParser struct{}
+
+func (endpointParser) Parse(message proto.Message) (proto.Message, error) {
+	endpoints, ok := message.(*envoy_config_endpoint.ClusterLoadAssignment)
+	if !ok {
+		return nil, fmt.Errorf("Invalid type for Route: %T", message)
+	}
+	if endpoints.ClusterName == "" {
+		return nil, fmt.Errorf("ClusterLoadAssignment cluster_name not provided")
+	}
+	return endpoints, nil
+}
+
+func (endpointParser) Mutate(msg proto.Message, opts ParseOptions) error {
+	return nil
+}
+
+func (endpointParser) Qualify(opts ParseOptions, msg proto.Message) string {
+	endpoints := msg.(*envoy_config_endpoint.ClusterLoadAssignment)
+	name := endpoints.ClusterName
+	endpoints.ClusterName, _ = api.ResourceQualifiedName(opts.CECNamespace, opts.CECName, name)
+	return name
+}
+
+func (endpointParser) Validate(msg proto.Message) error {
+	endpoints := msg.(*envoy_config_endpoint.ClusterLoadAssignment)
+	if err := endpoints.Validate(); err != nil {
+		return fmt.Errorf("ParseResources: Could not validate ClusterLoadAssignment for cluster %q (%s): %s", endpoints.ClusterName, err, endpoints.String())
+	}
+	return nil
+}
+
+// secretParser implements ResourceParser for Secret resources.
+type secretParser struct{}
+
+func (secretParser) Parse(message proto.Message) (proto.Message, error) {
+	secret, ok := message.(*envoy_config_tls.Secret)
+	if !ok {
+		return nil, fmt.Errorf("Invalid type for Secret: %T", message)
+	}
+	if secret.Name == "" {
+		return nil, fmt.Errorf("Secret name not provided")
+	}
+	return secret, nil
+}
+
+func (secretParser) Mutate(msg proto.Message, opts ParseOptions) error {
+	return nil
+}
+
+func (secretParser) Qualify(opts ParseOptions, msg proto.Message) string {
+	secret := msg.(*envoy_config_tls.Secret)
+	name := secret.Name
+	secret.Name, _ = api.ResourceQualifiedName(opts.CECNamespace, opts.CECName, name)
+	return name
+}
+
+func (secretParser) Validate(msg proto.Message) error {
+	secret := msg.(*envoy_config_tls.Secret)
+	if err := secret.Validate(); err != nil {
+		return fmt.Errorf("ParseResources: Could not validate Secret for cluster %q (%s)", secret.Name, err)
+	}
+	return nil
+}
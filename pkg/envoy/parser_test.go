@@ -0,0 +1,51 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package envoy
+
+import (
+	"testing"
+
+	envoy_config_cluster "github.com/cilium/proxy/go/envoy/config/cluster/v3"
+)
+
+func TestClusterParserChildResourcesEDSByName(t *testing.T) {
+	cluster := &envoy_config_cluster.Cluster{
+		Name: "test-namespace/test-cec/cluster",
+		Type: envoy_config_cluster.Cluster_EDS,
+	}
+
+	children := clusterParser{}.ChildResources(cluster)
+	names := children[EndpointTypeURL]
+	if len(names) != 1 || names[0] != cluster.Name {
+		t.Fatalf("expected EDS cluster to cascade to its own name %q, got %v", cluster.Name, names)
+	}
+}
+
+func TestClusterParserChildResourcesEDSServiceNameOverride(t *testing.T) {
+	cluster := &envoy_config_cluster.Cluster{
+		Name: "test-namespace/test-cec/cluster",
+		Type: envoy_config_cluster.Cluster_EDS,
+		EdsClusterConfig: &envoy_config_cluster.Cluster_EdsClusterConfig{
+			ServiceName: "test-namespace/test-cec/other-name",
+		},
+	}
+
+	children := clusterParser{}.ChildResources(cluster)
+	names := children[EndpointTypeURL]
+	if len(names) != 1 || names[0] != "test-namespace/test-cec/other-name" {
+		t.Fatalf("expected EDS cluster with ServiceName override to cascade to it, got %v", names)
+	}
+}
+
+func TestClusterParserChildResourcesNonEDSHasNoChildren(t *testing.T) {
+	cluster := &envoy_config_cluster.Cluster{
+		Name: "test-namespace/test-cec/cluster",
+		Type: envoy_config_cluster.Cluster_STATIC,
+	}
+
+	children := clusterParser{}.ChildResources(cluster)
+	if children != nil {
+		t.Fatalf("expected a non-EDS cluster to have no cascade children, got %v", children)
+	}
+}
@@ -0,0 +1,46 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package xds
+
+import "sync"
+
+// Broadcaster fans out a payload-less "something changed" wakeup to every subscribed Delta stream
+// for one TypeURL's DeltaCache; receivers recompute what to send via Subscription.NextResponse.
+type Broadcaster struct {
+	mutex sync.Mutex
+	subs  map[chan struct{}]struct{}
+}
+
+// NewBroadcaster creates an empty Broadcaster.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{
+		subs: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe registers a new receiver, returning its channel and a cancel func to unregister it (defer it).
+func (b *Broadcaster) Subscribe() (ch chan struct{}, cancel func()) {
+	ch = make(chan struct{}, 1)
+	b.mutex.Lock()
+	b.subs[ch] = struct{}{}
+	b.mutex.Unlock()
+	return ch, func() {
+		b.mutex.Lock()
+		delete(b.subs, ch)
+		b.mutex.Unlock()
+	}
+}
+
+// Publish wakes up every current subscriber; sends are non-blocking, so one already scheduled to
+// wake up is left alone rather than blocking the publisher or queuing a second signal.
+func (b *Broadcaster) Publish() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,312 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package xds
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ResourceVersion is an opaque per-resource version, the hash of its marshaled content so that
+// byte-identical updates collapse to the same version instead of generating a spurious push.
+type ResourceVersion string
+
+// hashResource computes the ResourceVersion of a resource's wire representation.
+func hashResource(msg proto.Message) (ResourceVersion, error) {
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return ResourceVersion(hex.EncodeToString(sum[:])), nil
+}
+
+// versionedResource is the cache's notion of the current state of a named resource. contentHash and
+// epoch are tracked separately so Touch can bump 'version' without the stored content changing.
+type versionedResource struct {
+	contentHash ResourceVersion
+	version     ResourceVersion
+	epoch       uint64
+	resource    proto.Message
+}
+
+// resourceVersion combines a content hash with its touch epoch; epoch 0 renders as the bare hash.
+func resourceVersion(contentHash ResourceVersion, epoch uint64) ResourceVersion {
+	if epoch == 0 {
+		return contentHash
+	}
+	return ResourceVersion(string(contentHash) + "-" + strconv.FormatUint(epoch, 10))
+}
+
+// DeltaCache is a per-TypeURL store of the latest version of every named resource, the source of
+// truth per-stream Subscriptions diff against to compute what to (re)send.
+type DeltaCache struct {
+	mutex     sync.RWMutex
+	resources map[string]versionedResource
+}
+
+// NewDeltaCache creates an empty DeltaCache for a single TypeURL.
+func NewDeltaCache() *DeltaCache {
+	return &DeltaCache{
+		resources: make(map[string]versionedResource),
+	}
+}
+
+// Update sets 'resource' as the current version for 'name', reporting whether the content actually changed.
+func (c *DeltaCache) Update(name string, resource proto.Message) (version ResourceVersion, changed bool, err error) {
+	contentHash, err := hashResource(resource)
+	if err != nil {
+		return "", false, err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	prev, ok := c.resources[name]
+	if ok && prev.contentHash == contentHash {
+		return prev.version, false, nil
+	}
+	version = resourceVersion(contentHash, 0)
+	c.resources[name] = versionedResource{contentHash: contentHash, version: version, resource: resource}
+	return version, true, nil
+}
+
+// Touch forces 'name' to a new version without altering its stored content (see cascadeChildRepush,
+// envoyproxy/envoy#13009), reporting whether 'name' was present to be touched at all.
+func (c *DeltaCache) Touch(name string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	prev, ok := c.resources[name]
+	if !ok {
+		return false
+	}
+	prev.epoch++
+	prev.version = resourceVersion(prev.contentHash, prev.epoch)
+	c.resources[name] = prev
+	return true
+}
+
+// Delete removes 'name' from the cache; every Subscription that has acked
+// 'name' then reports it in RemovedResources on its next Diff.
+func (c *DeltaCache) Delete(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.resources, name)
+}
+
+// Get returns the resource and version currently cached for 'name'.
+func (c *DeltaCache) Get(name string) (proto.Message, ResourceVersion, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	v, ok := c.resources[name]
+	return v.resource, v.version, ok
+}
+
+// Names returns the set of resource names currently known to the cache.
+// Used to compute the wildcard subscription view.
+func (c *DeltaCache) Names() []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	names := make([]string, 0, len(c.resources))
+	for name := range c.resources {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Subscription tracks, for a single Delta xDS stream and TypeURL, which resource versions the peer
+// already has, so only what changed since its last ACK needs to be (re)sent.
+type Subscription struct {
+	mutex sync.Mutex
+	// wildcard is true when the peer wants every resource of this TypeURL (empty subscribe list).
+	wildcard bool
+	// explicit holds the names the peer explicitly subscribed to; unused when wildcard is true.
+	explicit map[string]struct{}
+	// acked is the version of each name the peer has ACKed (directly, or via initial_resource_versions).
+	acked map[string]ResourceVersion
+	// nacked is the version of each name the peer most recently rejected; Diff skips re-offering it
+	// until the DeltaCache produces a version other than the one already known to be broken.
+	nacked map[string]ResourceVersion
+
+	// nonceSeq generates the nonce of the next response, so a peer's ACK/NACK can be correlated back to it.
+	nonceSeq uint64
+	// pending holds, per not-yet-resolved nonce, the names/versions it offered (a map since several
+	// responses can be in flight on one stream at once).
+	pending map[string]pendingResponse
+
+	// sentFirstResponse is set once NextResponse sends anything, including the forced-empty first
+	// response to a wildcard subscription (see NextResponse), so that exception fires only once.
+	sentFirstResponse bool
+}
+
+// pendingResponse is what a single DeltaDiscoveryResponse proposed, kept under its nonce until ACKed/NACKed.
+type pendingResponse struct {
+	versions map[string]ResourceVersion
+	removed  []string
+}
+
+// NewSubscription creates a per-stream Subscription for one TypeURL, seeded from the DeltaRequest's
+// resource_names_subscribe/initial_resource_versions so a reconnecting Envoy isn't resent what it has.
+func NewSubscription(subscribe []string, initialResourceVersions map[string]string) *Subscription {
+	s := &Subscription{
+		wildcard: len(subscribe) == 0,
+		explicit: make(map[string]struct{}, len(subscribe)),
+		acked:    make(map[string]ResourceVersion, len(initialResourceVersions)),
+		nacked:   make(map[string]ResourceVersion),
+		pending:  make(map[string]pendingResponse),
+	}
+	for _, name := range subscribe {
+		s.explicit[name] = struct{}{}
+	}
+	for name, version := range initialResourceVersions {
+		s.acked[name] = ResourceVersion(version)
+	}
+	return s
+}
+
+// Subscribe applies resource_names_subscribe / resource_names_unsubscribe
+// from a subsequent DeltaRequest on the same stream.
+func (s *Subscription) Subscribe(subscribe, unsubscribe []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, name := range unsubscribe {
+		delete(s.explicit, name)
+		delete(s.acked, name)
+	}
+	for _, name := range subscribe {
+		s.explicit[name] = struct{}{}
+	}
+}
+
+// Ack records that the peer has accepted 'name' at 'version'.
+func (s *Subscription) Ack(name string, version ResourceVersion) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.acked[name] = version
+}
+
+// Acked returns the version of 'name' this subscription's peer has most recently ACKed, so a caller
+// can tell whether a specific peer has picked up a given push (e.g. the old half of a CA rotation).
+func (s *Subscription) Acked(name string) (ResourceVersion, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	version, ok := s.acked[name]
+	return version, ok
+}
+
+// interested reports whether this subscription wants 'name' at all.
+func (s *Subscription) interested(name string) bool {
+	if s.wildcard {
+		return true
+	}
+	_, ok := s.explicit[name]
+	return ok
+}
+
+// Diff computes the Resources/RemovedResources a DeltaDiscoveryResponse should carry: 'updated' is
+// every interesting resource not yet acked at its current version (skipping one still at its nacked
+// version), 'removed' is every acked name the cache no longer has.
+func (s *Subscription) Diff(cache *DeltaCache) (updated map[string]proto.Message, removed []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	updated = make(map[string]proto.Message)
+	current := make(map[string]struct{})
+	for _, name := range cache.Names() {
+		if !s.interested(name) {
+			continue
+		}
+		current[name] = struct{}{}
+		resource, version, ok := cache.Get(name)
+		if !ok {
+			continue
+		}
+		if nacked, rejected := s.nacked[name]; rejected && nacked == version {
+			continue
+		}
+		if acked, seen := s.acked[name]; !seen || acked != version {
+			updated[name] = resource
+		}
+	}
+	for name := range s.acked {
+		if _, stillCurrent := current[name]; !stillCurrent && s.interested(name) {
+			removed = append(removed, name)
+		}
+	}
+	return updated, removed
+}
+
+// NextResponse computes the next DeltaDiscoveryResponse payload (same as Diff), assigning it a nonce
+// recorded in 'pending' for HandleAck to later resolve. Returns an empty nonce, meaning "send nothing",
+// when there's no change - except a wildcard subscription's very first response, which is always sent
+// even when empty, since some Envoys (seen on CDS) never finish warming without one.
+func (s *Subscription) NextResponse(cache *DeltaCache) (nonce string, updated map[string]proto.Message, removed []string) {
+	updated, removed = s.Diff(cache)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(updated) == 0 && len(removed) == 0 {
+		if !s.wildcard || s.sentFirstResponse {
+			return "", nil, nil
+		}
+	}
+	s.sentFirstResponse = true
+
+	versions := make(map[string]ResourceVersion, len(updated))
+	for name := range updated {
+		if _, version, ok := cache.Get(name); ok {
+			versions[name] = version
+		}
+	}
+
+	s.nonceSeq++
+	nonce = strconv.FormatUint(s.nonceSeq, 10)
+	s.pending[nonce] = pendingResponse{versions: versions, removed: removed}
+	return nonce, updated, removed
+}
+
+// HandleAck resolves the pending response offered under 'nonce' (a no-op if unknown): on ACK it
+// records every offered resource as acked and clears any nacked mark on it; on NACK it records the
+// offered version of every resource as nacked instead, leaving prior acked state untouched.
+func (s *Subscription) HandleAck(nonce string, nack bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pending, ok := s.pending[nonce]
+	if !ok {
+		return
+	}
+	delete(s.pending, nonce)
+	if nack {
+		for name, version := range pending.versions {
+			s.nacked[name] = version
+		}
+		return
+	}
+	for name, version := range pending.versions {
+		s.acked[name] = version
+		delete(s.nacked, name)
+	}
+	for _, name := range pending.removed {
+		delete(s.acked, name)
+	}
+}
+
+// NackedNames returns the resource names this subscription's peer most recently rejected and has not
+// since ACKed a different version of.
+func (s *Subscription) NackedNames() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.nacked) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(s.nacked))
+	for name := range s.nacked {
+		names = append(names, name)
+	}
+	return names
+}
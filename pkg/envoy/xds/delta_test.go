@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+// Copyright Authors of Cilium
+
+package xds
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestDeltaCacheUpdateChanged(t *testing.T) {
+	cache := NewDeltaCache()
+
+	version, changed, err := cache.Update("foo", wrapperspb.String("v1"))
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !changed {
+		t.Fatal("first Update of a name should report changed")
+	}
+
+	sameVersion, changed, err := cache.Update("foo", wrapperspb.String("v1"))
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if changed {
+		t.Fatal("re-Update with byte-identical content should not report changed")
+	}
+	if sameVersion != version {
+		t.Fatalf("byte-identical content should keep the same version, got %q want %q", sameVersion, version)
+	}
+
+	newVersion, changed, err := cache.Update("foo", wrapperspb.String("v2"))
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if !changed {
+		t.Fatal("Update with different content should report changed")
+	}
+	if newVersion == version {
+		t.Fatal("different content should produce a different version")
+	}
+}
+
+func TestDeltaCacheTouch(t *testing.T) {
+	cache := NewDeltaCache()
+	if cache.Touch("missing") {
+		t.Fatal("Touch of a name never Updated should report false")
+	}
+
+	version, _, _ := cache.Update("foo", wrapperspb.String("v1"))
+	if !cache.Touch("foo") {
+		t.Fatal("Touch of a present name should report true")
+	}
+	_, touchedVersion, ok := cache.Get("foo")
+	if !ok {
+		t.Fatal("Get after Touch should still find the resource")
+	}
+	if touchedVersion == version {
+		t.Fatal("Touch should change the version even though content did not change")
+	}
+}
+
+func TestDeltaCacheDelete(t *testing.T) {
+	cache := NewDeltaCache()
+	cache.Update("foo", wrapperspb.String("v1"))
+	cache.Delete("foo")
+	if _, _, ok := cache.Get("foo"); ok {
+		t.Fatal("Get after Delete should report not found")
+	}
+}
+
+func TestSubscriptionDiffWildcard(t *testing.T) {
+	cache := NewDeltaCache()
+	cache.Update("foo", wrapperspb.String("v1"))
+
+	sub := NewSubscription(nil, nil)
+	updated, removed := sub.Diff(cache)
+	if len(removed) != 0 {
+		t.Fatalf("expected no removals, got %v", removed)
+	}
+	if _, ok := updated["foo"]; !ok {
+		t.Fatalf("expected wildcard subscription to see unacked %q, got %v", "foo", updated)
+	}
+}
+
+func TestSubscriptionDiffSkipsNackedVersion(t *testing.T) {
+	cache := NewDeltaCache()
+	cache.Update("foo", wrapperspb.String("v1"))
+
+	sub := NewSubscription(nil, nil)
+	nonce, _, _ := sub.NextResponse(cache)
+	sub.HandleAck(nonce, true) // NACK
+
+	updated, _ := sub.Diff(cache)
+	if _, ok := updated["foo"]; ok {
+		t.Fatal("a name still at its nacked version should not be re-offered")
+	}
+
+	// A new version should be offered again even though the old one was nacked.
+	cache.Update("foo", wrapperspb.String("v2"))
+	updated, _ = sub.Diff(cache)
+	if _, ok := updated["foo"]; !ok {
+		t.Fatal("a new version should be offered even after the prior version was nacked")
+	}
+}
+
+func TestSubscriptionDiffRemovesDroppedResource(t *testing.T) {
+	cache := NewDeltaCache()
+	cache.Update("foo", wrapperspb.String("v1"))
+
+	sub := NewSubscription(nil, nil)
+	nonce, _, _ := sub.NextResponse(cache)
+	sub.HandleAck(nonce, false) // ACK
+
+	cache.Delete("foo")
+	updated, removed := sub.Diff(cache)
+	if len(updated) != 0 {
+		t.Fatalf("expected no updates, got %v", updated)
+	}
+	if len(removed) != 1 || removed[0] != "foo" {
+		t.Fatalf("expected foo to be reported removed, got %v", removed)
+	}
+}
+
+func TestNextResponseNoChangeIsNoOp(t *testing.T) {
+	cache := NewDeltaCache()
+	cache.Update("foo", wrapperspb.String("v1"))
+
+	sub := NewSubscription(nil, nil)
+	nonce, _, _ := sub.NextResponse(cache)
+	sub.HandleAck(nonce, false)
+
+	nonce, updated, removed := sub.NextResponse(cache)
+	if nonce != "" || updated != nil || removed != nil {
+		t.Fatalf("expected a no-op reconcile to return nothing, got nonce=%q updated=%v removed=%v", nonce, updated, removed)
+	}
+}
+
+// TestNextResponseFirstWildcardResponseIsSentEvenWhenEmpty guards against a
+// regression where a wildcard subscription with nothing to offer (e.g. a
+// CDS subscription against zero Clusters) never received any response at
+// all, which some Envoys block on indefinitely rather than treating as
+// "there is nothing of this type".
+func TestNextResponseFirstWildcardResponseIsSentEvenWhenEmpty(t *testing.T) {
+	cache := NewDeltaCache()
+	sub := NewSubscription(nil, nil)
+
+	nonce, updated, removed := sub.NextResponse(cache)
+	if nonce == "" {
+		t.Fatal("expected the first response to a wildcard subscription to be sent even when empty")
+	}
+	if len(updated) != 0 || len(removed) != 0 {
+		t.Fatalf("expected an empty response, got updated=%v removed=%v", updated, removed)
+	}
+
+	// The second empty reconcile in a row should go back to being a no-op.
+	nonce, _, _ = sub.NextResponse(cache)
+	if nonce != "" {
+		t.Fatal("expected a subsequent empty reconcile to be skipped")
+	}
+}
+
+func TestNextResponseFirstResponseNotForcedForExplicitSubscription(t *testing.T) {
+	cache := NewDeltaCache()
+	sub := NewSubscription([]string{"bar"}, nil)
+
+	nonce, _, _ := sub.NextResponse(cache)
+	if nonce != "" {
+		t.Fatal("an explicit (non-wildcard) subscription with nothing to offer should not get a forced empty response")
+	}
+}